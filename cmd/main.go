@@ -1,19 +1,43 @@
 package main
 
 import (
+	"expvar"
 	"fmt"
+	"net/http"
+	"time"
 
-	"github.com/carlosmeds/rate-limiter/configs"
+	cfg "github.com/carlosmeds/rate-limiter/configs"
 	"github.com/carlosmeds/rate-limiter/internal/infra/web"
 	"github.com/carlosmeds/rate-limiter/internal/infra/web/webserver"
 )
 
 func main() {
-	configs, err := configs.LoadConfig()
+	configs, err := cfg.LoadConfig()
 	if err != nil {
 		panic(err)
 	}
 
+	if configs.ConfigReloadInterval > 0 {
+		fmt.Println("Watching config for changes every", configs.ConfigReloadInterval)
+		cfg.WatchConfig(configs.ConfigReloadInterval)
+	}
+	if configs.MetricsAddr != "" {
+		fmt.Println("Serving metrics on", configs.MetricsAddr+"/debug/vars")
+		expvar.Publish("config_reloads", expvar.Func(func() any {
+			reloads := cfg.Reloads()
+			stats := map[string]any{"succeeded": reloads.Succeeded, "failed": reloads.Failed}
+			if !reloads.LastReload.IsZero() {
+				stats["last_reload"] = reloads.LastReload.Format(time.RFC3339)
+			}
+			return stats
+		}))
+		metrics := http.NewServeMux()
+		metrics.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			panic(http.ListenAndServe(configs.MetricsAddr, metrics))
+		}()
+	}
+
 	webserver := webserver.NewWebServer(":" + configs.WebServerPort)
 	webOrderHandler := web.NewWebIpHandler()
 	webserver.AddHandler("/ip", webOrderHandler.Get)
@@ -1,59 +1,265 @@
 package configs
 
 import (
-	"strconv"
+	"errors"
+	"fmt"
+	"net/netip"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	FixedWindow          = "fixed_window"
+	TokenBucket          = "token_bucket"
+	SlidingWindowLog     = "sliding_window_log"
+	SlidingWindowCounter = "sliding_window_counter"
+	GCRA                 = "gcra"
+	LeakyBucket          = "leaky_bucket"
+)
+
+const (
+	RedisBackend  = "redis"
+	MemoryBackend = "memory"
+	HybridBackend = "hybrid"
+)
+
+const (
+	RedisSingle   = "single"
+	RedisSentinel = "sentinel"
+	RedisCluster  = "cluster"
+)
+
+const (
+	FailOpen     = "open"
+	FailClosed   = "closed"
+	FailToMemory = "memory"
+)
+
+const (
+	TextFormat     = "text"
+	JSONFormat     = "json"
+	ProblemFormat  = "problem"
+	TemplateFormat = "template"
+)
+
 type Config struct {
-	DefaultLimit  int64  `mapstructure:"DEFAULT_LIMIT"`
-	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
-	BlockedTime   int64  `mapstructure:"BLOCKED_TIME"`
-	RedisAddr     string `mapstructure:"REDIS_ADDR"`
-	ApiKeyLimits  map[string]int64
+	WebServerPort           string             `mapstructure:"WEB_SERVER_PORT"`
+	BlockedTime             int64              `mapstructure:"BLOCKED_TIME"`
+	IPv4PrefixLength        int                `mapstructure:"IPV4_PREFIX_LENGTH"`
+	IPv6PrefixLength        int                `mapstructure:"IPV6_PREFIX_LENGTH"`
+	RedisMode               string             `mapstructure:"REDIS_MODE"`
+	RedisAddr               string             `mapstructure:"REDIS_ADDR"`
+	RedisMasterName         string             `mapstructure:"REDIS_MASTER_NAME"`
+	RedisSentinelPassword   string             `mapstructure:"REDIS_SENTINEL_PASSWORD"`
+	RedisUsername           string             `mapstructure:"REDIS_USERNAME"`
+	RedisPassword           string             `mapstructure:"REDIS_PASSWORD"`
+	RedisDB                 int                `mapstructure:"REDIS_DB"`
+	RedisTLS                bool               `mapstructure:"REDIS_TLS"`
+	RedisPoolSize           int                `mapstructure:"REDIS_POOL_SIZE"`
+	RedisMinIdleConns       int                `mapstructure:"REDIS_MIN_IDLE_CONNS"`
+	RedisDialTimeout        time.Duration      `mapstructure:"REDIS_DIAL_TIMEOUT"`
+	RedisReadTimeout        time.Duration      `mapstructure:"REDIS_READ_TIMEOUT"`
+	RedisWriteTimeout       time.Duration      `mapstructure:"REDIS_WRITE_TIMEOUT"`
+	RedisPoolTimeout        time.Duration      `mapstructure:"REDIS_POOL_TIMEOUT"`
+	DecisionTimeout         time.Duration      `mapstructure:"DECISION_TIMEOUT"`
+	PolicyFile              string             `mapstructure:"POLICY_FILE"`
+	AllowlistFile           string             `mapstructure:"ALLOWLIST_FILE"`
+	DenylistFile            string             `mapstructure:"DENYLIST_FILE"`
+	ConfigReloadInterval    time.Duration      `mapstructure:"CONFIG_RELOAD_INTERVAL"`
+	MetricsAddr             string             `mapstructure:"METRICS_ADDR"`
+	Algorithm               string             `mapstructure:"ALGORITHM"`
+	LeakyBucketMaxQueue     int64              `mapstructure:"LEAKY_BUCKET_MAX_QUEUE"`
+	LeakyBucketMaxWait      time.Duration      `mapstructure:"LEAKY_BUCKET_MAX_WAIT"`
+	StorageBackend          string             `mapstructure:"STORAGE_BACKEND"`
+	MemoryCleanupInterval   time.Duration      `mapstructure:"MEMORY_CLEANUP_INTERVAL"`
+	HybridSyncInterval      time.Duration      `mapstructure:"HYBRID_SYNC_INTERVAL"`
+	HybridOvershoot         float64            `mapstructure:"HYBRID_OVERSHOOT"`
+	FailurePolicy           string             `mapstructure:"FAILURE_POLICY"`
+	FailureRetryAfter       int64              `mapstructure:"FAILURE_RETRY_AFTER"`
+	BreakerFailureThreshold int                `mapstructure:"BREAKER_FAILURE_THRESHOLD"`
+	BreakerCooldown         time.Duration      `mapstructure:"BREAKER_COOLDOWN"`
+	BreakerHalfOpenRequests int                `mapstructure:"BREAKER_HALF_OPEN_REQUESTS"`
+	ErrorFormat             string             `mapstructure:"ERROR_FORMAT"`
+	ErrorTemplateFile       string             `mapstructure:"ERROR_TEMPLATE"`
+	DefaultLimits           []Limit            `mapstructure:"-"`
+	TrustedProxies          []netip.Prefix     `mapstructure:"-"`
+	Allowlist               *PrefixSet         `mapstructure:"-"`
+	Denylist                *PrefixSet         `mapstructure:"-"`
+	ApiKeyLimits            map[string][]Limit `mapstructure:"-"`
+	ApiKeyBlockedTimes      map[string]int64   `mapstructure:"-"`
+	ApiKeyTiers             map[string]string  `mapstructure:"-"`
+	Tiers                   map[string]Tier    `mapstructure:"-"`
+	RoutePolicies           []RoutePolicy      `mapstructure:"-"`
+	ErrorTemplate           *ErrorTemplate     `mapstructure:"-"`
 }
 
+const envFile = ".env"
+
 var (
-	config *Config
-	once   sync.Once
+	config atomic.Pointer[Config]
+	loadMu sync.Mutex
 )
 
+// LoadConfig reads the env file the first time it is called and returns the
+// current configuration afterwards. A failed load is returned and tried
+// again on the next call.
 func LoadConfig() (*Config, error) {
-	var err error
-	once.Do(func() {
-		viper.SetConfigName("app_config")
-		viper.SetConfigType("env")
-		viper.AddConfigPath(".")
-		viper.SetConfigFile(".env")
-		viper.AutomaticEnv()
-		err = viper.ReadInConfig()
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	if loaded := config.Load(); loaded != nil {
+		return loaded, nil
+	}
+	loaded, err := readConfig(envFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Store(loaded)
+	return loaded, nil
+}
+
+// readConfig reads the env file at path and every file it points to into a
+// new Config, returning the first problem found instead of a partial Config.
+func readConfig(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigName("app_config")
+	v.SetConfigType("env")
+	v.AddConfigPath(".")
+	v.SetConfigFile(path)
+	v.AutomaticEnv()
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	err = v.Unmarshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultLimit := v.GetString("DEFAULT_LIMIT"); defaultLimit != "" {
+		config.DefaultLimits, err = ParseLimits(defaultLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config.RoutePolicies, err = ParseRoutePolicies(v.GetString("ROUTE_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+
+	config.TrustedProxies, err = ParsePrefixes(v.GetString("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, err
+	}
+
+	config.Allowlist, err = loadPrefixSet(v.GetString("ALLOWLIST"), config.AllowlistFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Denylist, err = loadPrefixSet(v.GetString("DENYLIST"), config.DenylistFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Tiers, err = parseTiers(v.GetString("TIERS"))
+	if err != nil {
+		return nil, err
+	}
+	config.ApiKeyLimits, config.ApiKeyTiers, err = parseApiKeys(v.GetString("API_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	config.ApiKeyBlockedTimes = make(map[string]int64)
+
+	if config.RedisMode == RedisSentinel && config.RedisMasterName == "" {
+		return nil, errors.New("REDIS_MASTER_NAME is required when REDIS_MODE is sentinel")
+	}
+
+	switch config.ErrorFormat {
+	case "", TextFormat, JSONFormat, ProblemFormat:
+	case TemplateFormat:
+		if config.ErrorTemplateFile == "" {
+			return nil, errors.New("ERROR_TEMPLATE is required when ERROR_FORMAT is template")
+		}
+	default:
+		return nil, fmt.Errorf("unknown error format %q", config.ErrorFormat)
+	}
+	if config.ErrorTemplateFile != "" {
+		config.ErrorTemplate, err = LoadErrorTemplate(config.ErrorTemplateFile)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		err = viper.Unmarshal(&config)
+	}
+
+	if config.PolicyFile != "" {
+		err = LoadPolicyFile(config, config.PolicyFile)
 		if err != nil {
-			panic(err)
-		}
-
-		config.ApiKeyLimits = make(map[string]int64)
-		apiKeys := viper.GetString("API_KEYS")
-		for _, pair := range strings.Split(apiKeys, ",") {
-			parts := strings.Split(pair, ":")
-			if len(parts) == 2 {
-				apiKey := parts[0]
-				limit, err := strconv.ParseInt(parts[1], 10, 64)
-				if err == nil {
-					config.ApiKeyLimits[apiKey] = limit
-				}
+			return nil, err
+		}
+	}
+	if len(config.DefaultLimits) == 0 {
+		return nil, errors.New("DEFAULT_LIMIT or default_limit in the policy file is required")
+	}
+	for apiKey, tier := range config.ApiKeyTiers {
+		if _, ok := config.Tiers[tier]; !ok {
+			return nil, fmt.Errorf("unknown tier %q for API key %q", tier, apiKey)
+		}
+	}
+	return config, nil
+}
+
+// parseApiKeys reads "key:limits" pairs joined by ",", such as
+// "abc:10+1000/h,def:100/m". A key may name its tier instead of limits, as in
+// "abc:tier=pro".
+func parseApiKeys(value string) (map[string][]Limit, map[string]string, error) {
+	apiKeys := make(map[string][]Limit)
+	apiKeyTiers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		apiKey, limit, found := strings.Cut(pair, ":")
+		apiKey = strings.TrimSpace(apiKey)
+		if !found || apiKey == "" {
+			return nil, nil, fmt.Errorf("invalid API key entry %q", pair)
+		}
+		limit = strings.TrimSpace(limit)
+		if tier, ok := strings.CutPrefix(limit, "tier="); ok {
+			if tier = strings.TrimSpace(tier); tier == "" {
+				return nil, nil, fmt.Errorf("invalid API key entry %q: missing tier", pair)
 			}
+			apiKeyTiers[apiKey] = tier
+			continue
+		}
+		limits, err := ParseLimits(limit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid API key entry %q: %w", pair, err)
+		}
+		apiKeys[apiKey] = limits
+	}
+	return apiKeys, apiKeyTiers, nil
+}
+
+func loadPrefixSet(value, path string) (*PrefixSet, error) {
+	prefixes, err := ParsePrefixes(value)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		filePrefixes, err := ReadPrefixesFile(path)
+		if err != nil {
+			return nil, err
 		}
-	})
-	return config, err
+		prefixes = append(prefixes, filePrefixes...)
+	}
+	return NewPrefixSet(prefixes), nil
 }
 
 func GetConfig() *Config {
-	return config
+	return config.Load()
 }
@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApiKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string][]Limit
+		tiers    map[string]string
+		wantErr  bool
+	}{
+		{"empty", "", map[string][]Limit{}, map[string]string{}, false},
+		{
+			"several keys",
+			"abc:2, def:100/m+1000/h",
+			map[string][]Limit{"abc": {{2, time.Second}}, "def": {{100, time.Minute}, {1000, time.Hour}}},
+			map[string]string{},
+			false,
+		},
+		{"tiers", "abc:tier=pro,def:5", map[string][]Limit{"def": {{5, time.Second}}}, map[string]string{"abc": "pro"}, false},
+		{"tier name as limit", "abc:pro", nil, nil, true},
+		{"mistyped limit", "abc:1O/m", nil, nil, true},
+		{"missing tier", "abc:tier=", nil, nil, true},
+		{"trailing comma", "abc:2,", map[string][]Limit{"abc": {{2, time.Second}}}, map[string]string{}, false},
+		{"missing limit", "abc", nil, nil, true},
+		{"empty limit", "abc:", nil, nil, true},
+		{"missing key", ":10", nil, nil, true},
+		{"invalid limit", "abc:10/x", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeys, tiers, err := parseApiKeys(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, apiKeys)
+			assert.Equal(t, tt.tiers, tiers)
+		})
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	writeEnv(t, path, "DEFAULT_LIMIT=5\nALLOWLIST=10.0.0.0/8\nDENYLIST=198.51.100.0/24\nTIERS=pro:100/m\nAPI_KEYS=abc:tier=pro\n")
+
+	config, err := readConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Limit{{5, time.Second}}, config.DefaultLimits)
+	assert.True(t, config.Allowlist.Contains(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, config.Denylist.Contains(netip.MustParseAddr("198.51.100.7")))
+	assert.Equal(t, map[string]string{"abc": "pro"}, config.ApiKeyTiers)
+}
+
+func TestReadConfigSentinel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+
+	writeEnv(t, path, "DEFAULT_LIMIT=5\nREDIS_MODE=sentinel\n")
+	_, err := readConfig(path)
+	assert.EqualError(t, err, "REDIS_MASTER_NAME is required when REDIS_MODE is sentinel")
+
+	writeEnv(t, path, "DEFAULT_LIMIT=5\nREDIS_MODE=sentinel\nREDIS_MASTER_NAME=mymaster\n")
+	config, err := readConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "mymaster", config.RedisMasterName)
+}
@@ -0,0 +1,28 @@
+package configs
+
+import (
+	"mime"
+	"path/filepath"
+	"text/template"
+)
+
+// ErrorTemplate renders the body of rejected requests, sent with the content
+// type that matches the extension of its file.
+type ErrorTemplate struct {
+	*template.Template
+	ContentType string
+}
+
+// LoadErrorTemplate parses the Go template at path. A file whose extension
+// has no known content type is sent as plain text.
+func LoadErrorTemplate(path string) (*ErrorTemplate, error) {
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return &ErrorTemplate{Template: tmpl, ContentType: contentType}, nil
+}
@@ -0,0 +1,59 @@
+package configs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadErrorTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("content type from extension", func(t *testing.T) {
+		path := filepath.Join(dir, "rejection.json")
+		writeEnv(t, path, `{"message": "{{.Detail}}"}`)
+
+		tmpl, err := LoadErrorTemplate(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", tmpl.ContentType)
+
+		var body strings.Builder
+		assert.NoError(t, tmpl.Execute(&body, struct{ Detail string }{"slow down"}))
+		assert.Equal(t, `{"message": "slow down"}`, body.String())
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		path := filepath.Join(dir, "rejection.tmpl")
+		writeEnv(t, path, "{{.Detail}}")
+
+		tmpl, err := LoadErrorTemplate(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "text/plain; charset=utf-8", tmpl.ContentType)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.html")
+		writeEnv(t, path, "{{.Detail")
+
+		_, err := LoadErrorTemplate(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("config requires a template", func(t *testing.T) {
+		path := filepath.Join(dir, ".env")
+		writeEnv(t, path, "DEFAULT_LIMIT=5\nERROR_FORMAT=template\n")
+
+		_, err := readConfig(path)
+		assert.EqualError(t, err, "ERROR_TEMPLATE is required when ERROR_FORMAT is template")
+	})
+
+	t.Run("unknown error format", func(t *testing.T) {
+		path := filepath.Join(dir, ".env")
+		writeEnv(t, path, "DEFAULT_LIMIT=5\nERROR_FORMAT=xml\n")
+
+		_, err := readConfig(path)
+		assert.EqualError(t, err, `unknown error format "xml"`)
+	})
+}
@@ -0,0 +1,71 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Limit struct {
+	Requests int64
+	Window   time.Duration
+}
+
+var windowUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseLimits reads several limits joined by "+", such as "10/s+1000/h",
+// that must all hold at the same time.
+func ParseLimits(value string) ([]Limit, error) {
+	var limits []Limit
+	for _, part := range strings.Split(value, "+") {
+		limit, err := ParseLimit(part)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// ParseLimit reads limits such as "10", "100/m", "10000/h" or "50/30s". A
+// limit without a window is per second.
+func ParseLimit(value string) (Limit, error) {
+	requests, window, found := strings.Cut(strings.TrimSpace(value), "/")
+	limit := Limit{Window: time.Second}
+
+	var err error
+	limit.Requests, err = strconv.ParseInt(strings.TrimSpace(requests), 10, 64)
+	if err != nil || limit.Requests < 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q", value)
+	}
+	if !found {
+		return limit, nil
+	}
+
+	window = strings.TrimSpace(window)
+	if unit, ok := windowUnits[window]; ok {
+		limit.Window = unit
+		return limit, nil
+	}
+	// Stores count windows in milliseconds, so shorter windows would be 0.
+	limit.Window, err = time.ParseDuration(window)
+	if err != nil || limit.Window < time.Millisecond {
+		return Limit{}, fmt.Errorf("invalid window in limit %q", value)
+	}
+	return limit, nil
+}
+
+func (l Limit) String() string {
+	for unit, window := range windowUnits {
+		if l.Window == window {
+			return fmt.Sprintf("%d/%s", l.Requests, unit)
+		}
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
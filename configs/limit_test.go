@@ -0,0 +1,150 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectedLimit Limit
+		expectedErr   bool
+	}{
+		{
+			name:          "Requests only",
+			value:         "10",
+			expectedLimit: Limit{Requests: 10, Window: time.Second},
+		},
+		{
+			name:          "Per minute",
+			value:         "100/m",
+			expectedLimit: Limit{Requests: 100, Window: time.Minute},
+		},
+		{
+			name:          "Per hour",
+			value:         "10000/h",
+			expectedLimit: Limit{Requests: 10000, Window: time.Hour},
+		},
+		{
+			name:          "Per day",
+			value:         "5/d",
+			expectedLimit: Limit{Requests: 5, Window: 24 * time.Hour},
+		},
+		{
+			name:          "Duration window",
+			value:         "50/30s",
+			expectedLimit: Limit{Requests: 50, Window: 30 * time.Second},
+		},
+		{
+			name:        "Invalid requests",
+			value:       "ten/s",
+			expectedErr: true,
+		},
+		{
+			name:        "Negative requests",
+			value:       "-1/s",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid window",
+			value:       "10/week",
+			expectedErr: true,
+		},
+		{
+			name:        "Sub-millisecond window",
+			value:       "10/500us",
+			expectedErr: true,
+		},
+		{
+			name:          "Millisecond window",
+			value:         "1/1ms",
+			expectedLimit: Limit{Requests: 1, Window: time.Millisecond},
+		},
+		{
+			name:        "Empty",
+			value:       "",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseLimit(tt.value)
+			if tt.expectedErr && err == nil {
+				t.Errorf("Expected error for %q, got limit: %v", tt.value, limit)
+			}
+			if !tt.expectedErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if limit != tt.expectedLimit {
+				t.Errorf("Expected limit: %v, got: %v", tt.expectedLimit, limit)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		expectedLimits []Limit
+		expectedErr    bool
+	}{
+		{
+			name:           "Single limit",
+			value:          "10/s",
+			expectedLimits: []Limit{{Requests: 10, Window: time.Second}},
+		},
+		{
+			name:  "Burst and sustained limits",
+			value: "10/s+1000/h",
+			expectedLimits: []Limit{
+				{Requests: 10, Window: time.Second},
+				{Requests: 1000, Window: time.Hour},
+			},
+		},
+		{
+			name:        "One invalid limit",
+			value:       "10/s+abc/h",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, err := ParseLimits(tt.value)
+			if tt.expectedErr && err == nil {
+				t.Errorf("Expected error for %q, got limits: %v", tt.value, limits)
+			}
+			if !tt.expectedErr && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(limits, tt.expectedLimits) {
+				t.Errorf("Expected limits: %v, got: %v", tt.expectedLimits, limits)
+			}
+		})
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	tests := []struct {
+		limit    Limit
+		expected string
+	}{
+		{limit: Limit{Requests: 10, Window: time.Second}, expected: "10/s"},
+		{limit: Limit{Requests: 100, Window: time.Minute}, expected: "100/m"},
+		{limit: Limit{Requests: 5, Window: 24 * time.Hour}, expected: "5/d"},
+		{limit: Limit{Requests: 50, Window: 30 * time.Second}, expected: "50/30s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := tt.limit.String(); result != tt.expected {
+				t.Errorf("Expected: %v, got: %v", tt.expected, result)
+			}
+		})
+	}
+}
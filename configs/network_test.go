@@ -0,0 +1,64 @@
+package configs
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []netip.Prefix
+		wantErr  bool
+	}{
+		{"empty", "", nil, false},
+		{"cidrs", "10.0.0.0/8, 2001:db8::/32", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}, false},
+		{"bare addresses", "192.168.1.1,::1", []netip.Prefix{netip.MustParsePrefix("192.168.1.1/32"), netip.MustParsePrefix("::1/128")}, false},
+		{"host bits are masked", "192.168.1.1/24", []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}, false},
+		{"invalid address", "not-an-ip", nil, true},
+		{"invalid cidr", "10.0.0.0/33", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParsePrefixes(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, prefixes)
+		})
+	}
+}
+
+func TestReadPrefixesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("valid file", func(t *testing.T) {
+		path := filepath.Join(dir, "allowlist.txt")
+		os.WriteFile(path, []byte("# office\n203.0.113.0/24\n\n  2001:db8::1  \n"), 0o644)
+
+		prefixes, err := ReadPrefixesFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8::1/128")}, prefixes)
+	})
+
+	t.Run("invalid line", func(t *testing.T) {
+		path := filepath.Join(dir, "denylist.txt")
+		os.WriteFile(path, []byte("198.51.100.0/24\nnot-an-ip\n"), 0o644)
+
+		_, err := ReadPrefixesFile(path)
+		assert.ErrorContains(t, err, "denylist.txt:2")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := ReadPrefixesFile(filepath.Join(dir, "missing.txt"))
+		assert.Error(t, err)
+	})
+}
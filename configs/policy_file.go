@@ -0,0 +1,328 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var algorithms = map[string]bool{
+	FixedWindow:          true,
+	TokenBucket:          true,
+	SlidingWindowLog:     true,
+	SlidingWindowCounter: true,
+	GCRA:                 true,
+	LeakyBucket:          true,
+}
+
+// policy holds what a policy file sets. Fields left out of the file are
+// zero and keep the values from the environment.
+type policy struct {
+	Algorithm     string
+	BlockedTime   *int64
+	DefaultLimits []Limit
+	Tiers         map[string]Tier
+	ApiKeys       map[string]apiKeyPolicy
+	Routes        []RoutePolicy
+}
+
+type apiKeyPolicy struct {
+	Limits      []Limit
+	BlockedTime *int64
+	Tier        string
+	tierLine    int
+}
+
+// LoadPolicyFile reads the YAML or JSON policy file at path into config.
+// Every problem found in the file is reported with its line, and config is
+// left untouched unless the whole file is valid.
+func LoadPolicyFile(config *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	policy, err := parsePolicy(path, data)
+	if err != nil {
+		return err
+	}
+	var unknownTiers []string
+	for apiKey, keyPolicy := range policy.ApiKeys {
+		_, inFile := policy.Tiers[keyPolicy.Tier]
+		_, inConfig := config.Tiers[keyPolicy.Tier]
+		if keyPolicy.Tier != "" && !inFile && !inConfig {
+			unknownTiers = append(unknownTiers, fmt.Sprintf("%s:%d: unknown tier %q for API key %q", path, keyPolicy.tierLine, keyPolicy.Tier, apiKey))
+		}
+	}
+	if len(unknownTiers) > 0 {
+		sort.Strings(unknownTiers)
+		return errors.New(strings.Join(unknownTiers, "\n"))
+	}
+
+	if policy.Algorithm != "" {
+		config.Algorithm = policy.Algorithm
+	}
+	if policy.BlockedTime != nil {
+		config.BlockedTime = *policy.BlockedTime
+	}
+	if policy.DefaultLimits != nil {
+		config.DefaultLimits = policy.DefaultLimits
+	}
+	if config.Tiers == nil {
+		config.Tiers = make(map[string]Tier)
+	}
+	for name, tier := range policy.Tiers {
+		config.Tiers[name] = tier
+	}
+	if config.ApiKeyLimits == nil {
+		config.ApiKeyLimits = make(map[string][]Limit)
+	}
+	if config.ApiKeyBlockedTimes == nil {
+		config.ApiKeyBlockedTimes = make(map[string]int64)
+	}
+	if config.ApiKeyTiers == nil {
+		config.ApiKeyTiers = make(map[string]string)
+	}
+	for apiKey, keyPolicy := range policy.ApiKeys {
+		delete(config.ApiKeyLimits, apiKey)
+		delete(config.ApiKeyTiers, apiKey)
+		if keyPolicy.Limits != nil {
+			config.ApiKeyLimits[apiKey] = keyPolicy.Limits
+		}
+		if keyPolicy.Tier != "" {
+			config.ApiKeyTiers[apiKey] = keyPolicy.Tier
+		}
+		if keyPolicy.BlockedTime != nil {
+			config.ApiKeyBlockedTimes[apiKey] = *keyPolicy.BlockedTime
+		}
+	}
+	config.RoutePolicies = append(config.RoutePolicies, policy.Routes...)
+	return nil
+}
+
+func parsePolicy(path string, data []byte) (*policy, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	p := &policyParser{path: path}
+	result := &policy{}
+	if len(root.Content) == 0 {
+		return result, nil
+	}
+	p.fields(root.Content[0], func(key, value *yaml.Node) {
+		switch key.Value {
+		case "algorithm":
+			result.Algorithm = p.algorithm(value)
+		case "blocked_time":
+			result.BlockedTime = p.blockedTime(value)
+		case "default_limit":
+			result.DefaultLimits = p.limits(value)
+		case "tiers":
+			result.Tiers = p.tiers(value)
+		case "api_keys":
+			result.ApiKeys = p.apiKeys(value)
+		case "routes":
+			result.Routes = p.routes(value)
+		default:
+			p.errorf(key, "unknown field %q", key.Value)
+		}
+	})
+	if len(p.errors) > 0 {
+		return nil, errors.New(strings.Join(p.errors, "\n"))
+	}
+	return result, nil
+}
+
+type policyParser struct {
+	path   string
+	errors []string
+}
+
+func (p *policyParser) errorf(node *yaml.Node, format string, args ...any) {
+	p.errors = append(p.errors, fmt.Sprintf("%s:%d: %s", p.path, node.Line, fmt.Sprintf(format, args...)))
+}
+
+func (p *policyParser) fields(node *yaml.Node, field func(key, value *yaml.Node)) {
+	if node.Kind != yaml.MappingNode {
+		p.errorf(node, "expected a mapping")
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		field(node.Content[i], node.Content[i+1])
+	}
+}
+
+func (p *policyParser) scalar(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.ScalarNode {
+		p.errorf(node, "expected a single value")
+		return "", false
+	}
+	return node.Value, true
+}
+
+func (p *policyParser) algorithm(node *yaml.Node) string {
+	value, ok := p.scalar(node)
+	if ok && !algorithms[value] {
+		p.errorf(node, "unknown algorithm %q", value)
+	}
+	return value
+}
+
+func (p *policyParser) failurePolicy(node *yaml.Node) string {
+	value, ok := p.scalar(node)
+	if ok && value != FailOpen && value != FailClosed && value != FailToMemory {
+		p.errorf(node, "unknown failure policy %q", value)
+	}
+	return value
+}
+
+func (p *policyParser) blockedTime(node *yaml.Node) *int64 {
+	value, ok := p.scalar(node)
+	if !ok {
+		return nil
+	}
+	blockedTime, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || blockedTime < 0 {
+		p.errorf(node, "invalid blocked time %q", value)
+		return nil
+	}
+	return &blockedTime
+}
+
+// limits reads limits written as "10/s+1000/h" or as a list of limits.
+func (p *policyParser) limits(node *yaml.Node) []Limit {
+	var limits []Limit
+	switch node.Kind {
+	case yaml.ScalarNode:
+		parsed, err := ParseLimits(node.Value)
+		if err != nil {
+			p.errorf(node, "%v", err)
+		}
+		limits = parsed
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			value, ok := p.scalar(item)
+			if !ok {
+				continue
+			}
+			limit, err := ParseLimit(value)
+			if err != nil {
+				p.errorf(item, "%v", err)
+				continue
+			}
+			limits = append(limits, limit)
+		}
+		if len(node.Content) == 0 {
+			p.errorf(node, "expected at least one limit")
+		}
+	default:
+		p.errorf(node, "expected a limit or a list of limits")
+	}
+	return limits
+}
+
+func (p *policyParser) tiers(node *yaml.Node) map[string]Tier {
+	tiers := make(map[string]Tier)
+	p.fields(node, func(key, value *yaml.Node) {
+		if _, exists := tiers[key.Value]; exists {
+			p.errorf(key, "duplicate tier %q", key.Value)
+		}
+		var tier Tier
+		var hasLimits bool
+		p.fields(value, func(field, value *yaml.Node) {
+			switch field.Value {
+			case "limits":
+				tier.Limits, hasLimits = p.limits(value), true
+			case "blocked_time":
+				tier.BlockedTime = p.blockedTime(value)
+			case "algorithm":
+				tier.Algorithm = p.algorithm(value)
+			default:
+				p.errorf(field, "unknown field %q", field.Value)
+			}
+		})
+		if value.Kind == yaml.MappingNode && !hasLimits {
+			p.errorf(key, "missing limits for tier %q", key.Value)
+		}
+		tiers[key.Value] = tier
+	})
+	return tiers
+}
+
+func (p *policyParser) apiKeys(node *yaml.Node) map[string]apiKeyPolicy {
+	apiKeys := make(map[string]apiKeyPolicy)
+	p.fields(node, func(key, value *yaml.Node) {
+		if _, exists := apiKeys[key.Value]; exists {
+			p.errorf(key, "duplicate API key %q", key.Value)
+		}
+		var keyPolicy apiKeyPolicy
+		var hasLimits bool
+		p.fields(value, func(field, value *yaml.Node) {
+			switch field.Value {
+			case "limits":
+				keyPolicy.Limits, hasLimits = p.limits(value), true
+			case "blocked_time":
+				keyPolicy.BlockedTime = p.blockedTime(value)
+			case "tier":
+				keyPolicy.Tier, _ = p.scalar(value)
+				keyPolicy.tierLine = value.Line
+			default:
+				p.errorf(field, "unknown field %q", field.Value)
+			}
+		})
+		if value.Kind == yaml.MappingNode && !hasLimits && keyPolicy.Tier == "" {
+			p.errorf(key, "missing limits or tier for API key %q", key.Value)
+		}
+		apiKeys[key.Value] = keyPolicy
+	})
+	return apiKeys
+}
+
+func (p *policyParser) routes(node *yaml.Node) []RoutePolicy {
+	if node.Kind != yaml.SequenceNode {
+		p.errorf(node, "expected a list of routes")
+		return nil
+	}
+	var routes []RoutePolicy
+	for _, item := range node.Content {
+		var route RoutePolicy
+		var method, pattern string
+		var hasLimits bool
+		p.fields(item, func(field, value *yaml.Node) {
+			switch field.Value {
+			case "method":
+				method, _ = p.scalar(value)
+			case "pattern":
+				pattern, _ = p.scalar(value)
+			case "limits":
+				route.Limits, hasLimits = p.limits(value), true
+			case "failure_policy":
+				route.FailurePolicy = p.failurePolicy(value)
+			default:
+				p.errorf(field, "unknown field %q", field.Value)
+			}
+		})
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		parsed, err := parseRoute(strings.TrimSpace(method + " " + pattern))
+		if err != nil {
+			p.errorf(item, "%v", err)
+			continue
+		}
+		if !hasLimits {
+			p.errorf(item, "missing limits for route %q", parsed.String())
+			continue
+		}
+		parsed.Limits = route.Limits
+		parsed.FailurePolicy = route.FailurePolicy
+		routes = append(routes, parsed)
+	}
+	return routes
+}
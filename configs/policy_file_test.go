@@ -0,0 +1,177 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlPolicy = `
+algorithm: sliding_window_counter
+blocked_time: 120
+default_limit: 10/s+1000/h
+api_keys:
+  abc123:
+    limits: [100/m]
+    blocked_time: 30
+  def456:
+    limits: 5
+routes:
+  - method: POST
+    pattern: /login
+    limits: 5/m
+    failure_policy: closed
+  - pattern: /users/{id}
+    limits: [20/s, 500/h]
+`
+
+const jsonPolicy = `{
+	"default_limit": "10",
+	"api_keys": {
+		"abc123": {"limits": ["100/m"]}
+	},
+	"routes": [
+		{"method": "post", "pattern": "/login", "limits": "5/m"}
+	]
+}`
+
+func writePolicy(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		config := &Config{
+			Algorithm:    FixedWindow,
+			BlockedTime:  300,
+			ApiKeyLimits: map[string][]Limit{"env-key": {{2, time.Second}}, "def456": {{1, time.Second}}},
+		}
+		err := LoadPolicyFile(config, writePolicy(t, "policy.yaml", yamlPolicy))
+		assert.NoError(t, err)
+
+		assert.Equal(t, SlidingWindowCounter, config.Algorithm)
+		assert.Equal(t, int64(120), config.BlockedTime)
+		assert.Equal(t, []Limit{{10, time.Second}, {1000, time.Hour}}, config.DefaultLimits)
+		assert.Equal(t, map[string][]Limit{
+			"env-key": {{2, time.Second}},
+			"abc123":  {{100, time.Minute}},
+			"def456":  {{5, time.Second}},
+		}, config.ApiKeyLimits)
+		assert.Equal(t, map[string]int64{"abc123": 30}, config.ApiKeyBlockedTimes)
+		assert.Equal(t, []RoutePolicy{
+			{Method: "POST", Pattern: "/login", Limits: []Limit{{5, time.Minute}}, FailurePolicy: FailClosed},
+			{Pattern: "/users/{id}", Limits: []Limit{{20, time.Second}, {500, time.Hour}}},
+		}, config.RoutePolicies)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		config := &Config{Algorithm: GCRA, BlockedTime: 300}
+		err := LoadPolicyFile(config, writePolicy(t, "policy.json", jsonPolicy))
+		assert.NoError(t, err)
+
+		assert.Equal(t, GCRA, config.Algorithm)
+		assert.Equal(t, int64(300), config.BlockedTime)
+		assert.Equal(t, []Limit{{10, time.Second}}, config.DefaultLimits)
+		assert.Equal(t, map[string][]Limit{"abc123": {{100, time.Minute}}}, config.ApiKeyLimits)
+		assert.Equal(t, []RoutePolicy{{Method: "POST", Pattern: "/login", Limits: []Limit{{5, time.Minute}}}}, config.RoutePolicies)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		config := &Config{BlockedTime: 300}
+		assert.NoError(t, LoadPolicyFile(config, writePolicy(t, "policy.yaml", "")))
+		assert.Equal(t, int64(300), config.BlockedTime)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		assert.Error(t, LoadPolicyFile(&Config{}, filepath.Join(t.TempDir(), "missing.yaml")))
+	})
+
+	t.Run("invalid file leaves config untouched", func(t *testing.T) {
+		config := &Config{BlockedTime: 300}
+		err := LoadPolicyFile(config, writePolicy(t, "policy.yaml", "blocked_time: 60\nalgorithm: unknown\n"))
+		assert.Error(t, err)
+		assert.Equal(t, int64(300), config.BlockedTime)
+	})
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "syntax error",
+			content:  "api_keys: [\n",
+			expected: []string{"policy.yaml: yaml: line 1"},
+		},
+		{
+			name:     "unknown field",
+			content:  "default_limit: 10\nlimits: 5\n",
+			expected: []string{"policy.yaml:2: unknown field \"limits\""},
+		},
+		{
+			name:     "unknown failure policy",
+			content:  "routes:\n  - pattern: /login\n    limits: 5/m\n    failure_policy: retry\n",
+			expected: []string{"policy.yaml:4: unknown failure policy \"retry\""},
+		},
+		{
+			name:     "not a mapping",
+			content:  "- 10\n",
+			expected: []string{"policy.yaml:1: expected a mapping"},
+		},
+		{
+			name: "every error is reported",
+			content: `algorithm: fastest
+blocked_time: -1
+default_limit: [10/s, ten/m]
+api_keys:
+  abc:
+    limit: 10
+  def:
+    limits: []
+routes:
+  - method: FETCH
+    pattern: /login
+    limits: 5
+  - pattern: /ip
+  - pattern: relative
+    limits: 5
+`,
+			expected: []string{
+				"policy.yaml:1: unknown algorithm \"fastest\"",
+				"policy.yaml:2: invalid blocked time \"-1\"",
+				"policy.yaml:3: invalid limit \"ten/m\"",
+				"policy.yaml:6: unknown field \"limit\"",
+				"policy.yaml:5: missing limits or tier for API key \"abc\"",
+				"policy.yaml:8: expected at least one limit",
+				"policy.yaml:10: invalid method in route \"FETCH /login\"",
+				"policy.yaml:13: missing limits for route \"/ip\"",
+				"policy.yaml:14: route pattern must begin with '/' in \"relative\"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePolicy("policy.yaml", []byte(tt.content))
+			if !assert.Error(t, err) {
+				return
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(tt.expected) > 1 {
+				assert.Equal(t, tt.expected, lines)
+				return
+			}
+			assert.Contains(t, err.Error(), tt.expected[0])
+		})
+	}
+}
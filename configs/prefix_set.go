@@ -0,0 +1,75 @@
+package configs
+
+import "net/netip"
+
+// PrefixSet is a set of networks stored in a binary trie per address family,
+// so looking up an address costs at most one step per bit of the address no
+// matter how many networks the set holds.
+type PrefixSet struct {
+	ipv4 *prefixNode
+	ipv6 *prefixNode
+}
+
+type prefixNode struct {
+	children [2]*prefixNode
+	terminal bool
+}
+
+func NewPrefixSet(prefixes []netip.Prefix) *PrefixSet {
+	set := &PrefixSet{ipv4: &prefixNode{}, ipv6: &prefixNode{}}
+	for _, prefix := range prefixes {
+		set.Add(prefix)
+	}
+	return set
+}
+
+// Add adds the network prefix. IPv4-mapped IPv6 networks such as
+// ::ffff:10.0.0.0/104 are stored as the IPv4 networks they map, since
+// Contains looks up IPv4-mapped addresses as IPv4 addresses.
+func (s *PrefixSet) Add(prefix netip.Prefix) {
+	prefix = prefix.Masked()
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	node := s.root(prefix.Addr())
+	bytes := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			return
+		}
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &prefixNode{}
+		}
+		node = node.children[bit]
+	}
+	node.terminal = true
+	node.children = [2]*prefixNode{}
+}
+
+// Contains reports whether addr belongs to any network of the set.
+func (s *PrefixSet) Contains(addr netip.Addr) bool {
+	if s == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	node := s.root(addr)
+	bytes := addr.AsSlice()
+	for i := 0; node != nil; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == len(bytes)*8 {
+			return false
+		}
+		node = node.children[bytes[i/8]>>(7-i%8)&1]
+	}
+	return false
+}
+
+func (s *PrefixSet) root(addr netip.Addr) *prefixNode {
+	if addr.Is4() {
+		return s.ipv4
+	}
+	return s.ipv6
+}
@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixSet(t *testing.T) {
+	set := NewPrefixSet([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.0/24"),
+		netip.MustParsePrefix("192.168.1.128/25"),
+		netip.MustParsePrefix("203.0.113.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("::ffff:172.16.0.0/108"),
+	})
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.168.1.200", true},
+		{"192.168.2.1", false},
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::1", false},
+		{"172.16.5.1", true},
+		{"::ffff:172.31.0.1", true},
+		{"172.32.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.expected, set.Contains(netip.MustParseAddr(tt.addr)))
+		})
+	}
+
+	t.Run("wider prefix added later", func(t *testing.T) {
+		set := NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("10.0.0.0/8")})
+		assert.True(t, set.Contains(netip.MustParseAddr("10.200.0.1")))
+	})
+
+	t.Run("match all", func(t *testing.T) {
+		set := NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")})
+		assert.True(t, set.Contains(netip.MustParseAddr("1.2.3.4")))
+		assert.False(t, set.Contains(netip.MustParseAddr("::1")))
+	})
+
+	t.Run("nil and empty sets", func(t *testing.T) {
+		var nilSet *PrefixSet
+		assert.False(t, nilSet.Contains(netip.MustParseAddr("10.0.0.1")))
+		assert.False(t, NewPrefixSet(nil).Contains(netip.MustParseAddr("10.0.0.1")))
+	})
+}
@@ -0,0 +1,153 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloadStats counts the succeeded and failed reloads and records the time of
+// the last one that succeeded.
+type ReloadStats struct {
+	Succeeded  int64
+	Failed     int64
+	LastReload time.Time
+}
+
+var (
+	reloadsMu sync.Mutex
+	reloads   ReloadStats
+)
+
+// Reloads returns the reloads done so far, so the server can publish them
+// with its metrics.
+func Reloads() ReloadStats {
+	reloadsMu.Lock()
+	defer reloadsMu.Unlock()
+	return reloads
+}
+
+func recordReload(err error) {
+	reloadsMu.Lock()
+	defer reloadsMu.Unlock()
+	if err != nil {
+		reloads.Failed++
+		return
+	}
+	reloads.Succeeded++
+	reloads.LastReload = time.Now()
+}
+
+// ReloadConfig reads the configuration again and swaps it in for the current
+// one. A configuration that fails to load is rejected and the current one is
+// kept.
+func ReloadConfig() error {
+	return reloadConfig(envFile)
+}
+
+func reloadConfig(path string) error {
+	loaded, err := readConfig(path)
+	if err != nil {
+		recordReload(err)
+		fmt.Println("Config reload rejected, keeping the previous config:", err)
+		return err
+	}
+
+	if previous := config.Load(); previous != nil {
+		if changed := restartOnlyChanges(previous, loaded); len(changed) > 0 {
+			fmt.Println("Config reloaded, restart to apply changes to", strings.Join(changed, ", "))
+		}
+	}
+	config.Store(loaded)
+	recordReload(nil)
+	fmt.Println("Config reloaded")
+	return nil
+}
+
+// restartOnlyChanges lists the settings that changed between previous and
+// next but are only read on start, such as the algorithm and the store.
+func restartOnlyChanges(previous, next *Config) []string {
+	var changed []string
+	check := func(name string, previous, next any) {
+		if previous != next {
+			changed = append(changed, name)
+		}
+	}
+	check("WEB_SERVER_PORT", previous.WebServerPort, next.WebServerPort)
+	check("ALGORITHM", previous.Algorithm, next.Algorithm)
+	check("STORAGE_BACKEND", previous.StorageBackend, next.StorageBackend)
+	check("REDIS_MODE", previous.RedisMode, next.RedisMode)
+	check("REDIS_ADDR", previous.RedisAddr, next.RedisAddr)
+	check("REDIS_MASTER_NAME", previous.RedisMasterName, next.RedisMasterName)
+	check("REDIS_SENTINEL_PASSWORD", previous.RedisSentinelPassword, next.RedisSentinelPassword)
+	check("REDIS_USERNAME", previous.RedisUsername, next.RedisUsername)
+	check("REDIS_PASSWORD", previous.RedisPassword, next.RedisPassword)
+	check("REDIS_DB", previous.RedisDB, next.RedisDB)
+	check("REDIS_TLS", previous.RedisTLS, next.RedisTLS)
+	check("REDIS_POOL_SIZE", previous.RedisPoolSize, next.RedisPoolSize)
+	check("REDIS_MIN_IDLE_CONNS", previous.RedisMinIdleConns, next.RedisMinIdleConns)
+	check("REDIS_DIAL_TIMEOUT", previous.RedisDialTimeout, next.RedisDialTimeout)
+	check("REDIS_READ_TIMEOUT", previous.RedisReadTimeout, next.RedisReadTimeout)
+	check("REDIS_WRITE_TIMEOUT", previous.RedisWriteTimeout, next.RedisWriteTimeout)
+	check("REDIS_POOL_TIMEOUT", previous.RedisPoolTimeout, next.RedisPoolTimeout)
+	check("LEAKY_BUCKET_MAX_QUEUE", previous.LeakyBucketMaxQueue, next.LeakyBucketMaxQueue)
+	check("LEAKY_BUCKET_MAX_WAIT", previous.LeakyBucketMaxWait, next.LeakyBucketMaxWait)
+	check("MEMORY_CLEANUP_INTERVAL", previous.MemoryCleanupInterval, next.MemoryCleanupInterval)
+	check("HYBRID_SYNC_INTERVAL", previous.HybridSyncInterval, next.HybridSyncInterval)
+	check("HYBRID_OVERSHOOT", previous.HybridOvershoot, next.HybridOvershoot)
+	check("BREAKER_FAILURE_THRESHOLD", previous.BreakerFailureThreshold, next.BreakerFailureThreshold)
+	check("BREAKER_COOLDOWN", previous.BreakerCooldown, next.BreakerCooldown)
+	check("BREAKER_HALF_OPEN_REQUESTS", previous.BreakerHalfOpenRequests, next.BreakerHalfOpenRequests)
+	check("CONFIG_RELOAD_INTERVAL", previous.ConfigReloadInterval, next.ConfigReloadInterval)
+	check("METRICS_ADDR", previous.MetricsAddr, next.MetricsAddr)
+	return changed
+}
+
+// WatchConfig reloads the configuration whenever the env file, or a file it
+// points to, changes. Files are checked every interval.
+func WatchConfig(interval time.Duration) {
+	go watchConfig(envFile, configFingerprint(envFile, config.Load()), interval, nil)
+}
+
+func watchConfig(path, last string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if configFingerprint(path, config.Load()) == last {
+				continue
+			}
+			reloadConfig(path)
+			last = configFingerprint(path, config.Load())
+		}
+	}
+}
+
+// configFingerprint changes whenever one of the files the configuration is
+// read from is written, created or removed.
+func configFingerprint(path string, current *Config) string {
+	paths := []string{path}
+	if current != nil {
+		paths = append(paths, current.PolicyFile, current.AllowlistFile, current.DenylistFile, current.ErrorTemplateFile)
+	}
+
+	var fingerprint strings.Builder
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&fingerprint, "%s:missing;", path)
+			continue
+		}
+		fmt.Fprintf(&fingerprint, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return fingerprint.String()
+}
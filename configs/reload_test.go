@@ -0,0 +1,125 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeEnv(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	writeEnv(t, path, "DEFAULT_LIMIT=5\nAPI_KEYS=abc:10\n")
+	loaded, err := readConfig(path)
+	assert.NoError(t, err)
+	config.Store(loaded)
+	defer config.Store(nil)
+
+	t.Run("valid config is swapped in", func(t *testing.T) {
+		succeeded := reloadCount("succeeded")
+		writeEnv(t, path, "DEFAULT_LIMIT=50/m\nAPI_KEYS=abc:10,def:100/m\n")
+
+		assert.NoError(t, reloadConfig(path))
+		assert.Equal(t, []Limit{{50, time.Minute}}, GetConfig().DefaultLimits)
+		assert.Equal(t, []Limit{{100, time.Minute}}, GetConfig().ApiKeyLimits["def"])
+		assert.Equal(t, succeeded+1, reloadCount("succeeded"))
+	})
+
+	t.Run("invalid config is rejected", func(t *testing.T) {
+		failed := reloadCount("failed")
+		previous := GetConfig()
+		writeEnv(t, path, "DEFAULT_LIMIT=5\nAPI_KEYS=abc\n")
+
+		assert.Error(t, reloadConfig(path))
+		assert.Same(t, previous, GetConfig())
+		assert.Equal(t, failed+1, reloadCount("failed"))
+	})
+
+	t.Run("invalid policy file is rejected", func(t *testing.T) {
+		previous := GetConfig()
+		policyPath := writePolicy(t, "policy.yaml", "default_limit: ten\n")
+		writeEnv(t, path, "DEFAULT_LIMIT=5\nPOLICY_FILE="+policyPath+"\n")
+
+		assert.Error(t, reloadConfig(path))
+		assert.Same(t, previous, GetConfig())
+	})
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	policyPath := writePolicy(t, "policy.yaml", "default_limit: 5\n")
+	writeEnv(t, path, "POLICY_FILE="+policyPath+"\n")
+	loaded, err := readConfig(path)
+	assert.NoError(t, err)
+	config.Store(loaded)
+	defer config.Store(nil)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchConfig(path, configFingerprint(path, loaded), 10*time.Millisecond, stop)
+
+	writeEnv(t, policyPath, "default_limit: 50/m\n")
+	assert.Eventually(t, func() bool {
+		return GetConfig().DefaultLimits[0] == Limit{50, time.Minute}
+	}, time.Second, 10*time.Millisecond)
+
+	writeEnv(t, path, "POLICY_FILE="+policyPath+"\nDEFAULT_LIMIT=oops\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, Limit{50, time.Minute}, GetConfig().DefaultLimits[0])
+}
+
+func TestRestartOnlyChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *Config
+		next     *Config
+		expected []string
+	}{
+		{
+			name:     "algorithm",
+			previous: &Config{Algorithm: FixedWindow, BlockedTime: 300},
+			next:     &Config{Algorithm: GCRA, BlockedTime: 60},
+			expected: []string{"ALGORITHM"},
+		},
+		{
+			name:     "redis connection",
+			previous: &Config{RedisPassword: "old", RedisDB: 0, RedisTLS: false, RedisReadTimeout: time.Second},
+			next:     &Config{RedisPassword: "new", RedisDB: 1, RedisTLS: true, RedisReadTimeout: 2 * time.Second},
+			expected: []string{"REDIS_PASSWORD", "REDIS_DB", "REDIS_TLS", "REDIS_READ_TIMEOUT"},
+		},
+		{
+			name:     "stores and breaker",
+			previous: &Config{MemoryCleanupInterval: time.Minute, HybridOvershoot: 0, BreakerCooldown: 5 * time.Second, BreakerHalfOpenRequests: 1},
+			next:     &Config{MemoryCleanupInterval: time.Hour, HybridOvershoot: 0.1, BreakerCooldown: 10 * time.Second, BreakerHalfOpenRequests: 2},
+			expected: []string{"MEMORY_CLEANUP_INTERVAL", "HYBRID_OVERSHOOT", "BREAKER_COOLDOWN", "BREAKER_HALF_OPEN_REQUESTS"},
+		},
+		{
+			name:     "limits only",
+			previous: &Config{DecisionTimeout: time.Second, FailurePolicy: FailOpen},
+			next:     &Config{DecisionTimeout: 2 * time.Second, FailurePolicy: FailClosed},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, restartOnlyChanges(tt.previous, tt.next))
+		})
+	}
+}
+
+func reloadCount(name string) int64 {
+	if name == "failed" {
+		return Reloads().Failed
+	}
+	return Reloads().Succeeded
+}
@@ -0,0 +1,119 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+var routeMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// RoutePolicy replaces the limits of every client on the routes matching
+// Pattern, a chi route pattern such as "/users/{id}", including the limits of
+// API keys and their tiers. Requests are still counted per client: per API
+// key when one is sent and per IP otherwise. An empty Method matches every
+// method. FailurePolicy, when set, replaces FAILURE_POLICY on the route.
+type RoutePolicy struct {
+	Method        string
+	Pattern       string
+	Limits        []Limit
+	FailurePolicy string
+}
+
+// ParseRoutePolicies reads policies such as "POST /login=5/m,/admin/*=1/s"
+// joined by ",". Commas inside the braces of a pattern, as in
+// "/{id:[0-9]{1,3}}", belong to the pattern.
+func ParseRoutePolicies(value string) ([]RoutePolicy, error) {
+	entries, err := splitRoutePolicies(value)
+	if err != nil {
+		return nil, err
+	}
+	var policies []RoutePolicy
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			return nil, fmt.Errorf("invalid route policy %q", entry)
+		}
+		policy, err := NewRoutePolicy(entry[:separator], entry[separator+1:])
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func splitRoutePolicies(value string) ([]string, error) {
+	var entries []string
+	depth, start := 0, 0
+	for i, c := range value {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				entries = append(entries, value[start:i])
+				start = i + 1
+			}
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("unbalanced braces in route policies %q", value)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced braces in route policies %q", value)
+	}
+	return append(entries, value[start:]), nil
+}
+
+// NewRoutePolicy reads a route such as "POST /login", or "/login" for every
+// method, and the limits applied to it.
+func NewRoutePolicy(route, limits string) (RoutePolicy, error) {
+	policy, err := parseRoute(route)
+	if err != nil {
+		return RoutePolicy{}, err
+	}
+	policy.Limits, err = ParseLimits(limits)
+	if err != nil {
+		return RoutePolicy{}, err
+	}
+	return policy, nil
+}
+
+func parseRoute(route string) (RoutePolicy, error) {
+	var policy RoutePolicy
+	fields := strings.Fields(route)
+	switch len(fields) {
+	case 1:
+		policy.Pattern = fields[0]
+	case 2:
+		policy.Method, policy.Pattern = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return RoutePolicy{}, fmt.Errorf("invalid route %q", route)
+	}
+	if policy.Method == "*" {
+		policy.Method = ""
+	}
+	if policy.Method != "" && !routeMethods[policy.Method] {
+		return RoutePolicy{}, fmt.Errorf("invalid method in route %q", route)
+	}
+	if !strings.HasPrefix(policy.Pattern, "/") {
+		return RoutePolicy{}, fmt.Errorf("route pattern must begin with '/' in %q", route)
+	}
+	return policy, nil
+}
+
+func (p RoutePolicy) String() string {
+	if p.Method == "" {
+		return p.Pattern
+	}
+	return p.Method + " " + p.Pattern
+}
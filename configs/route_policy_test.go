@@ -0,0 +1,67 @@
+package configs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoutePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []RoutePolicy
+		wantErr  bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"method and pattern",
+			"POST /login=5/m",
+			[]RoutePolicy{{Method: "POST", Pattern: "/login", Limits: []Limit{{5, time.Minute}}}},
+			false,
+		},
+		{
+			"any method",
+			"/admin/*=1, * /users/{id}=10/s+100/h",
+			[]RoutePolicy{
+				{Pattern: "/admin/*", Limits: []Limit{{1, time.Second}}},
+				{Pattern: "/users/{id}", Limits: []Limit{{10, time.Second}, {100, time.Hour}}},
+			},
+			false,
+		},
+		{
+			"comma in regexp",
+			"GET /users/{id:[0-9]{1,3}}=5/m,/ip=2",
+			[]RoutePolicy{
+				{Method: "GET", Pattern: "/users/{id:[0-9]{1,3}}", Limits: []Limit{{5, time.Minute}}},
+				{Pattern: "/ip", Limits: []Limit{{2, time.Second}}},
+			},
+			false,
+		},
+		{"unbalanced braces", "/users/{id=5,/ip=2", nil, true},
+		{"lowercase method", "get /ip=2", []RoutePolicy{{Method: "GET", Pattern: "/ip", Limits: []Limit{{2, time.Second}}}}, false},
+		{"missing limits", "POST /login", nil, true},
+		{"invalid limits", "POST /login=abc", nil, true},
+		{"invalid method", "FETCH /login=5", nil, true},
+		{"relative pattern", "POST login=5", nil, true},
+		{"too many fields", "POST /login extra=5", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := ParseRoutePolicies(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, policies)
+		})
+	}
+}
+
+func TestRoutePolicyString(t *testing.T) {
+	assert.Equal(t, "POST /login", RoutePolicy{Method: "POST", Pattern: "/login"}.String())
+	assert.Equal(t, "/admin/*", RoutePolicy{Pattern: "/admin/*"}.String())
+}
@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tier is a plan shared by many API keys, so changing the plan changes the
+// limits of every key on it. BlockedTime and Algorithm are optional and fall
+// back to BLOCKED_TIME and ALGORITHM.
+type Tier struct {
+	Limits      []Limit
+	BlockedTime *int64
+	Algorithm   string
+}
+
+// KeyPolicy is what applies to one client once its tier and overrides are
+// resolved. An empty Algorithm is the ALGORITHM in use.
+type KeyPolicy struct {
+	Limits      []Limit
+	BlockedTime int64
+	Algorithm   string
+}
+
+// KeyPolicy resolves the policy of apiKey: the limits, blocked time and
+// algorithm of its tier, replaced by the ones set for the key itself. Clients
+// without an API key get the default policy. It reports false for unknown
+// API keys.
+func (c *Config) KeyPolicy(apiKey string) (KeyPolicy, bool) {
+	policy := KeyPolicy{Limits: c.DefaultLimits, BlockedTime: c.BlockedTime}
+	if apiKey == "" {
+		return policy, true
+	}
+
+	tierName, hasTier := c.ApiKeyTiers[apiKey]
+	limits, hasLimits := c.ApiKeyLimits[apiKey]
+	if !hasTier && !hasLimits {
+		return KeyPolicy{}, false
+	}
+	if tier, ok := c.Tiers[tierName]; hasTier && ok {
+		policy.Limits = tier.Limits
+		policy.Algorithm = tier.Algorithm
+		if tier.BlockedTime != nil {
+			policy.BlockedTime = *tier.BlockedTime
+		}
+	}
+	if hasLimits {
+		policy.Limits = limits
+	}
+	if blockedTime, ok := c.ApiKeyBlockedTimes[apiKey]; ok {
+		policy.BlockedTime = blockedTime
+	}
+	return policy, true
+}
+
+// parseTiers reads "name:limits" pairs joined by ",", such as
+// "free:10/m,pro:100/m+10000/d".
+func parseTiers(value string) (map[string]Tier, error) {
+	tiers := make(map[string]Tier)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, limit, found := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid tier entry %q", pair)
+		}
+		limits, err := ParseLimits(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier entry %q: %w", pair, err)
+		}
+		tiers[name] = Tier{Limits: limits}
+	}
+	return tiers, nil
+}
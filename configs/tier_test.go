@@ -0,0 +1,114 @@
+package configs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	tierBlockedTime := int64(60)
+	config := &Config{
+		DefaultLimits: []Limit{{10, time.Second}},
+		BlockedTime:   300,
+		Tiers: map[string]Tier{
+			"free": {Limits: []Limit{{10, time.Minute}}},
+			"pro":  {Limits: []Limit{{100, time.Minute}}, BlockedTime: &tierBlockedTime, Algorithm: GCRA},
+		},
+		ApiKeyTiers:        map[string]string{"free-key": "free", "pro-key": "pro", "custom-pro-key": "pro"},
+		ApiKeyLimits:       map[string][]Limit{"custom-pro-key": {{500, time.Minute}}, "legacy-key": {{2, time.Second}}},
+		ApiKeyBlockedTimes: map[string]int64{"custom-pro-key": 0},
+	}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		expected KeyPolicy
+		found    bool
+	}{
+		{"no API key", "", KeyPolicy{Limits: []Limit{{10, time.Second}}, BlockedTime: 300}, true},
+		{"tier", "free-key", KeyPolicy{Limits: []Limit{{10, time.Minute}}, BlockedTime: 300}, true},
+		{"tier with blocked time and algorithm", "pro-key", KeyPolicy{Limits: []Limit{{100, time.Minute}}, BlockedTime: 60, Algorithm: GCRA}, true},
+		{"overrides", "custom-pro-key", KeyPolicy{Limits: []Limit{{500, time.Minute}}, BlockedTime: 0, Algorithm: GCRA}, true},
+		{"limits without tier", "legacy-key", KeyPolicy{Limits: []Limit{{2, time.Second}}, BlockedTime: 300}, true},
+		{"unknown key", "unknown-key", KeyPolicy{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, found := config.KeyPolicy(tt.apiKey)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := parseTiers("free:10/m, pro:100/m+10000/d")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Tier{
+		"free": {Limits: []Limit{{10, time.Minute}}},
+		"pro":  {Limits: []Limit{{100, time.Minute}, {10000, 24 * time.Hour}}},
+	}, tiers)
+
+	_, err = parseTiers("free")
+	assert.Error(t, err)
+	_, err = parseTiers("free:ten")
+	assert.Error(t, err)
+}
+
+func TestTiersFromPolicyFile(t *testing.T) {
+	t.Run("tiers and keys", func(t *testing.T) {
+		config := &Config{Tiers: map[string]Tier{"env-tier": {Limits: []Limit{{1, time.Second}}}}}
+		err := LoadPolicyFile(config, writePolicy(t, "policy.yaml", `
+tiers:
+  free:
+    limits: 10/m
+  pro:
+    limits: [100/m, 10000/d]
+    blocked_time: 60
+    algorithm: gcra
+api_keys:
+  free-key:
+    tier: free
+  custom-pro-key:
+    tier: pro
+    limits: 500/m
+  env-tier-key:
+    tier: env-tier
+`))
+		assert.NoError(t, err)
+
+		blockedTime := int64(60)
+		assert.Equal(t, Tier{Limits: []Limit{{100, time.Minute}, {10000, 24 * time.Hour}}, BlockedTime: &blockedTime, Algorithm: GCRA}, config.Tiers["pro"])
+		assert.Equal(t, map[string]string{"free-key": "free", "custom-pro-key": "pro", "env-tier-key": "env-tier"}, config.ApiKeyTiers)
+		assert.Equal(t, map[string][]Limit{"custom-pro-key": {{500, time.Minute}}}, config.ApiKeyLimits)
+	})
+
+	t.Run("invalid tiers", func(t *testing.T) {
+		path := writePolicy(t, "policy.yaml", `tiers:
+  free:
+    blocked_time: 60
+  pro:
+    limits: 100/m
+    algorithm: fastest
+`)
+		err := LoadPolicyFile(&Config{}, path)
+		assert.EqualError(t, err, path+`:2: missing limits for tier "free"`+"\n"+path+`:6: unknown algorithm "fastest"`)
+	})
+
+	t.Run("unknown tier", func(t *testing.T) {
+		path := writePolicy(t, "policy.yaml", "api_keys:\n  abc:\n    tier: gold\n")
+		err := LoadPolicyFile(&Config{}, path)
+		assert.EqualError(t, err, path+`:3: unknown tier "gold" for API key "abc"`)
+	})
+}
+
+func TestReadConfigUnknownTier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	writeEnv(t, path, "DEFAULT_LIMIT=5\nTIERS=pro:100/m\nAPI_KEYS=abc:tier=pro,def:tier=gold\n")
+	_, err := readConfig(path)
+	assert.EqualError(t, err, `unknown tier "gold" for API key "def"`)
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker is a redis.Hook that stops sending commands to Redis after
+// FailureThreshold consecutive failures. Once Cooldown has passed it lets up
+// to HalfOpenRequests probes through, closing again on the first success.
+type CircuitBreaker struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+	HalfOpenRequests int
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	probes   int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration, halfOpenRequests int) *CircuitBreaker {
+	if cooldown <= 0 {
+		cooldown = 5 * time.Second
+	}
+	if halfOpenRequests <= 0 {
+		halfOpenRequests = 1
+	}
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		Cooldown:         cooldown,
+		HalfOpenRequests: halfOpenRequests,
+		now:              time.Now,
+	}
+}
+
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && !cb.now().Before(cb.openedAt.Add(cb.Cooldown)) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Before(cb.openedAt.Add(cb.Cooldown)) {
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probes = 0
+	case CircuitClosed:
+		return nil
+	}
+
+	if cb.probes >= cb.HalfOpenRequests {
+		return ErrCircuitOpen
+	}
+	cb.probes++
+	return nil
+}
+
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	// A call cancelled by the client tells nothing about Redis, so the state
+	// is left alone and a probe gives its slot back.
+	if errors.Is(err, context.Canceled) {
+		if cb.state == CircuitHalfOpen && cb.probes > 0 {
+			cb.probes--
+		}
+		return
+	}
+	if !isStoreFailure(err) {
+		cb.failures = 0
+		if cb.state == CircuitHalfOpen {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.FailureThreshold {
+		cb.setState(CircuitOpen)
+		cb.openedAt = cb.now()
+	}
+}
+
+// setState must be called with the breaker locked.
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state != state {
+		fmt.Println("Circuit breaker", state)
+	}
+	cb.state = state
+}
+
+// isStoreFailure ignores replies such as redis.Nil or NOSCRIPT, which prove
+// Redis is up.
+func isStoreFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return false
+	}
+	return true
+}
+
+func (cb *CircuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (cb *CircuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := cb.allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		cb.record(err)
+		return err
+	}
+}
+
+func (cb *CircuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := cb.allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		cb.record(err)
+		return err
+	}
+}
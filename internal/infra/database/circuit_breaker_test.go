@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCircuitBreaker(now *time.Time) *CircuitBreaker {
+	cb := NewCircuitBreaker(3, 10*time.Second, 1)
+	cb.now = func() time.Time { return *now }
+	return cb
+}
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+
+	t.Run("stays closed below threshold", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, cb.allow())
+			cb.record(redis.ErrClosed)
+		}
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("success resets consecutive failures", func(t *testing.T) {
+		assert.NoError(t, cb.allow())
+		cb.record(nil)
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, cb.allow())
+			cb.record(redis.ErrClosed)
+		}
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("opens at threshold", func(t *testing.T) {
+		assert.NoError(t, cb.allow())
+		cb.record(context.DeadlineExceeded)
+		assert.Equal(t, CircuitOpen, cb.State())
+		assert.ErrorIs(t, cb.allow(), ErrCircuitOpen)
+	})
+
+	t.Run("half-open after cooldown allows one probe", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+		assert.NoError(t, cb.allow())
+		assert.ErrorIs(t, cb.allow(), ErrCircuitOpen)
+	})
+
+	t.Run("cancelled probe keeps the circuit half-open", func(t *testing.T) {
+		cb.record(context.Canceled)
+		assert.Equal(t, CircuitHalfOpen, cb.State())
+		assert.NoError(t, cb.allow())
+		assert.ErrorIs(t, cb.allow(), ErrCircuitOpen)
+	})
+
+	t.Run("failed probe reopens", func(t *testing.T) {
+		cb.record(redis.ErrClosed)
+		assert.Equal(t, CircuitOpen, cb.State())
+	})
+
+	t.Run("successful probe closes", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		assert.NoError(t, cb.allow())
+		cb.record(nil)
+		assert.Equal(t, CircuitClosed, cb.State())
+		assert.NoError(t, cb.allow())
+	})
+}
+
+func TestCircuitBreaker_IgnoresRedisReplies(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+
+	for i := 0; i < 5; i++ {
+		cb.record(redis.Nil)
+		cb.record(noScriptError("NOSCRIPT No matching script. Please use EVAL."))
+		cb.record(context.Canceled)
+	}
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreaker_ProcessHook(t *testing.T) {
+	now := time.Now()
+	cb := newTestCircuitBreaker(&now)
+	ctx := context.Background()
+	calls := 0
+	process := cb.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		calls++
+		return redis.ErrClosed
+	})
+
+	for i := 0; i < 5; i++ {
+		cmd := redis.NewStringCmd(ctx, "get", "key")
+		err := process(ctx, cmd)
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 3, calls)
+	cmd := redis.NewStringCmd(ctx, "get", "key")
+	assert.ErrorIs(t, process(ctx, cmd), ErrCircuitOpen)
+	assert.ErrorIs(t, cmd.Err(), ErrCircuitOpen)
+}
+
+func TestRateLimiterRepository_CircuitOpen(t *testing.T) {
+	now := time.Now()
+
+	t.Run("no breaker", func(t *testing.T) {
+		repo := &RateLimiterRepository{}
+		assert.False(t, repo.CircuitOpen())
+	})
+
+	t.Run("open breaker", func(t *testing.T) {
+		repo := &RateLimiterRepository{Breaker: newTestCircuitBreaker(&now)}
+		for i := 0; i < 3; i++ {
+			repo.Breaker.record(redis.ErrClosed)
+		}
+		assert.True(t, repo.CircuitOpen())
+	})
+}
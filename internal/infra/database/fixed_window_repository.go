@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+const fixedWindowLua = `
+local blockedTime = tonumber(ARGV[1])
+local blockedFor = redis.call('PTTL', KEYS[1])
+if blockedFor ~= -2 then
+	local blockedBy = redis.call('GET', KEYS[1])
+	for i = 2, #KEYS do
+		if blockedBy == 'Too many requests: ' .. ARGV[i * 2 - 2] .. '/' .. ARGV[i * 2 - 1] .. 'ms' then
+			return {-1, blockedFor, i - 1}
+		end
+	end
+	return {-1, blockedFor, 0}
+end
+
+local reply = {0, 0, 0, 0, 0}
+for i = 2, #KEYS do
+	local limit = tonumber(ARGV[i * 2 - 2])
+	local count = redis.call('INCR', KEYS[i])
+	if count == 1 then
+		redis.call('PEXPIRE', KEYS[i], ARGV[i * 2 - 1])
+	end
+	local ttl = redis.call('PTTL', KEYS[i])
+	if reply[1] == 0 and count > limit then
+		reply = {1, i - 1, 0, ttl, ttl}
+	elseif reply[1] == 0 and (reply[2] == 0 or limit - count < reply[3]) then
+		reply = {0, i - 1, limit - count, 0, ttl}
+	end
+end
+
+if reply[1] == 1 and blockedTime > 0 then
+	local tripped = reply[2] + 1
+	local blockedBy = 'Too many requests: ' .. ARGV[tripped * 2 - 2] .. '/' .. ARGV[tripped * 2 - 1] .. 'ms'
+	redis.call('SET', KEYS[1], blockedBy, 'EX', blockedTime)
+	reply[4] = math.max(reply[4], blockedTime * 1000)
+end
+return reply
+`
+
+var fixedWindowScript = redis.NewScript(fixedWindowLua)
+
+type FixedWindowRepository struct {
+	*RateLimiterRepository
+}
+
+// CheckAndBlock checks the blacklist, counts the request against every limit
+// and blacklists the client for blockedTime seconds when a limit trips, all
+// within one script execution. A rejected client is told to retry once its
+// block and the tripped window are over. The blacklist names the tripped
+// limit like BlackListValue, so blacklisted clients are told about it too.
+func (r *FixedWindowRepository) CheckAndBlock(ctx context.Context, blackListKey, apiKey string, limits []configs.Limit, blockedTime int64) (*LimitResult, error) {
+	keys := append([]string{blackListKey}, limitKeys(apiKey, limits)...)
+	args := []interface{}{blockedTime}
+	for _, limit := range limits {
+		args = append(args, limit.Requests, limit.Window.Milliseconds())
+	}
+
+	reply, err := fixedWindowScript.Run(ctx, r.RedisClient, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reply) == 3 && reply[0] < 0 {
+		return blackListedResult(limits, int(reply[2])-1, time.Duration(reply[1])*time.Millisecond), nil
+	}
+	return scriptResult(limits, reply)
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWindowRepository_CheckAndBlock(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &FixedWindowRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perHour := configs.Limit{Requests: 100, Window: time.Hour}
+
+	t.Run("request within limits", func(t *testing.T) {
+		keys := []string{"blacklist@api_key_1", "requests@api_key_1:5/s", "requests@api_key_1:100/h"}
+
+		mock.ExpectEvalSha(fixedWindowScript.Hash(), keys, int64(300), int64(5), int64(1000), int64(100), int64(3600000)).
+			SetVal([]interface{}{int64(0), int64(1), int64(4), int64(0), int64(1000)})
+
+		result, err := repo.CheckAndBlock(ctx, "blacklist@api_key_1", "requests@api_key_1", []configs.Limit{perSecond, perHour}, 300)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.False(t, result.BlackListed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(4), result.Remaining)
+		assert.Equal(t, time.Second, result.ResetAfter)
+	})
+
+	t.Run("request exceeds limit", func(t *testing.T) {
+		keys := []string{"blacklist@api_key_2", "requests@api_key_2:5/s", "requests@api_key_2:100/h"}
+
+		mock.ExpectEvalSha(fixedWindowScript.Hash(), keys, int64(300), int64(5), int64(1000), int64(100), int64(3600000)).
+			SetVal([]interface{}{int64(1), int64(2), int64(0), int64(1800000), int64(1800000)})
+
+		result, err := repo.CheckAndBlock(ctx, "blacklist@api_key_2", "requests@api_key_2", []configs.Limit{perSecond, perHour}, 300)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.False(t, result.BlackListed)
+		assert.Equal(t, perHour, result.Limit)
+		assert.Equal(t, 30*time.Minute, result.RetryAfter)
+	})
+
+	t.Run("client blacklisted", func(t *testing.T) {
+		keys := []string{"blacklist@api_key_3", "requests@api_key_3:5/s"}
+
+		mock.ExpectEvalSha(fixedWindowScript.Hash(), keys, int64(300), int64(5), int64(1000)).SetVal([]interface{}{int64(-1), int64(120000), int64(1)})
+
+		result, err := repo.CheckAndBlock(ctx, "blacklist@api_key_3", "requests@api_key_3", []configs.Limit{perSecond}, 300)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.True(t, result.BlackListed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, 2*time.Minute, result.RetryAfter)
+		assert.Equal(t, 2*time.Minute, result.ResetAfter)
+	})
+
+	t.Run("script not loaded", func(t *testing.T) {
+		keys := []string{"blacklist@api_key_4", "requests@api_key_4:5/s"}
+
+		mock.ExpectEvalSha(fixedWindowScript.Hash(), keys, int64(300), int64(5), int64(1000)).
+			SetErr(noScriptError("NOSCRIPT No matching script. Please use EVAL."))
+		mock.ExpectEval(fixedWindowLua, keys, int64(300), int64(5), int64(1000)).
+			SetVal([]interface{}{int64(0), int64(1), int64(4), int64(0), int64(1000)})
+
+		result, err := repo.CheckAndBlock(ctx, "blacklist@api_key_4", "requests@api_key_4", []configs.Limit{perSecond}, 300)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		keys := []string{"blacklist@api_key_5", "requests@api_key_5:5/s"}
+
+		mock.ExpectEvalSha(fixedWindowScript.Hash(), keys, int64(300), int64(5), int64(1000)).SetErr(redis.ErrClosed)
+
+		result, err := repo.CheckAndBlock(ctx, "blacklist@api_key_5", "requests@api_key_5", []configs.Limit{perSecond}, 300)
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// noScriptError mimics the reply Redis sends when a script is not cached yet
+type noScriptError string
+
+func (e noScriptError) Error() string { return string(e) }
+
+func (e noScriptError) RedisError() {}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+var gcraScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local tats = {}
+local reply = {0, 0, 0, 0, 0}
+for i = 1, #KEYS do
+	local burst = tonumber(ARGV[i * 2 - 1])
+	local interval = tonumber(ARGV[i * 2])
+	local tat = math.max(tonumber(redis.call('GET', KEYS[i])) or now, now)
+	local newTat = tat + interval
+	local diff = now - (newTat - interval * burst)
+	if diff < 0 then
+		return {1, i, 0, math.ceil(-diff), math.ceil(tat - now)}
+	end
+
+	tats[i] = newTat
+	local remaining = math.floor(diff / interval)
+	if reply[2] == 0 or remaining < reply[3] then
+		reply = {0, i, remaining, 0, math.ceil(newTat - now)}
+	end
+end
+
+for i = 1, #KEYS do
+	redis.call('SET', KEYS[i], tostring(tats[i]), 'PX', math.ceil(tats[i] - now))
+end
+return reply
+`)
+
+type GCRARepository struct {
+	*RateLimiterRepository
+}
+
+// ExactRetryAfter reports that a denied client may retry as soon as
+// RetryAfter is over, so it is never blacklisted.
+func (r *GCRARepository) ExactRetryAfter() bool {
+	return true
+}
+
+// HasReachedLimit stores only the theoretical arrival time of the next
+// request per limit, spacing requests evenly while still allowing a burst of
+// limit.Requests per window.
+func (r *GCRARepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	if limit, blocked := blockedLimit(limits); blocked {
+		return &LimitResult{Allowed: false, Limit: limit, RetryAfter: limit.Window, ResetAfter: limit.Window}, nil
+	}
+
+	args := make([]interface{}, 0, len(limits)*2)
+	for _, limit := range limits {
+		args = append(args, limit.Requests, float64(limit.Window.Milliseconds())/float64(limit.Requests))
+	}
+
+	reply, err := gcraScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return scriptResult(limits, reply)
+}
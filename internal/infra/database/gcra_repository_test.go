@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCRARepository_HasReachedLimit(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &GCRARepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perHour := configs.Limit{Requests: 3600, Window: time.Hour}
+
+	t.Run("request allowed", func(t *testing.T) {
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(gcraScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(200)).
+			SetVal([]interface{}{int64(0), int64(1), int64(4), int64(0), int64(200)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(4), result.Remaining)
+		assert.Equal(t, time.Duration(0), result.RetryAfter)
+		assert.Equal(t, 200*time.Millisecond, result.ResetAfter)
+	})
+
+	t.Run("request denied", func(t *testing.T) {
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(gcraScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(200)).
+			SetVal([]interface{}{int64(1), int64(1), int64(0), int64(150), int64(950)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, 150*time.Millisecond, result.RetryAfter)
+		assert.Equal(t, 950*time.Millisecond, result.ResetAfter)
+	})
+
+	t.Run("request denied by hour limit", func(t *testing.T) {
+		apiKey := "api_key_3"
+
+		mock.ExpectEvalSha(gcraScript.Hash(), []string{apiKey + ":5/s", apiKey + ":3600/h"},
+			int64(5), float64(200), int64(3600), float64(1000)).
+			SetVal([]interface{}{int64(1), int64(2), int64(0), int64(400), int64(3600000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perHour})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perHour, result.Limit)
+		assert.Equal(t, 400*time.Millisecond, result.RetryAfter)
+	})
+
+	t.Run("zero limit", func(t *testing.T) {
+		result, err := repo.HasReachedLimit(ctx, "api_key_4", []configs.Limit{{Window: time.Second}})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		apiKey := "api_key_5"
+
+		mock.ExpectEvalSha(gcraScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(200)).SetErr(redis.ErrClosed)
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
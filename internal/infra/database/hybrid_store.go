@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+type hybridCounter struct {
+	key     string
+	window  time.Duration
+	start   time.Time
+	global  int64
+	pending int64
+}
+
+type hybridCacheEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// HybridStore decides locally using approximate fixed window counters and
+// syncs the local deltas with Redis every SyncInterval, so replicas converge
+// on the global count without a round trip per request. The last synced global
+// count is enforced exactly, while the requests counted locally since then may
+// exceed what it left of the limit by the Overshoot fraction.
+type HybridStore struct {
+	*RateLimiterRepository
+	SyncInterval time.Duration
+	Overshoot    float64
+
+	mu       sync.Mutex
+	counters map[string]*hybridCounter
+	cache    map[string]hybridCacheEntry
+	done     chan struct{}
+	once     sync.Once
+	now      func() time.Time
+}
+
+func NewHybridStore(syncInterval time.Duration, overshoot float64) *HybridStore {
+	s := newHybridStore(NewRateLimiterRepository(), syncInterval, overshoot)
+	go s.syncLoop()
+	return s
+}
+
+func newHybridStore(repo *RateLimiterRepository, syncInterval time.Duration, overshoot float64) *HybridStore {
+	return &HybridStore{
+		RateLimiterRepository: repo,
+		SyncInterval:          syncInterval,
+		Overshoot:             overshoot,
+		counters:              make(map[string]*hybridCounter),
+		cache:                 make(map[string]hybridCacheEntry),
+		done:                  make(chan struct{}),
+		now:                   time.Now,
+	}
+}
+
+// Get answers from the local cache and only asks Redis once per SyncInterval
+// for each key, remembering misses as well.
+func (s *HybridStore) Get(ctx context.Context, key string) (string, error) {
+	now := s.now()
+	s.mu.Lock()
+	entry, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := s.RateLimiterRepository.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	s.remember(key, value, now.Add(s.SyncInterval))
+	return value, nil
+}
+
+func (s *HybridStore) Save(ctx context.Context, key, value string, ttl int64) error {
+	err := s.RateLimiterRepository.Save(ctx, key, value, ttl)
+	if err != nil {
+		return err
+	}
+	s.remember(key, value, s.now().Add(time.Duration(ttl)*time.Second))
+	return nil
+}
+
+// HasReachedLimit counts the request locally against the last known global
+// count of every limit's current window.
+func (s *HybridStore) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters := make([]*hybridCounter, len(limits))
+	result := &LimitResult{Allowed: true}
+	for i, key := range limitKeys(apiKey, limits) {
+		counter := s.counter(key, limits[i].Window, now)
+		resetAfter := counter.start.Add(counter.window).Sub(now)
+		left := limits[i].Requests - counter.global
+		if left <= 0 || float64(counter.pending+1) > float64(left)*(1+s.Overshoot) {
+			return &LimitResult{Allowed: false, Limit: limits[i], RetryAfter: resetAfter, ResetAfter: resetAfter}, nil
+		}
+		counters[i] = counter
+		result.tighten(limits[i], max(0, limits[i].Requests-counter.global-counter.pending-1), resetAfter)
+	}
+
+	for _, counter := range counters {
+		counter.pending++
+	}
+	return result, nil
+}
+
+// Close stops the background sync.
+func (s *HybridStore) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *HybridStore) syncLoop() {
+	ticker := time.NewTicker(s.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := s.sync(context.Background())
+			if err != nil {
+				fmt.Println("Error syncing counters", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+type hybridDelta struct {
+	counter *hybridCounter
+	start   time.Time
+	delta   int64
+	total   *redis.IntCmd
+}
+
+// sync flushes the pending increments of every live window to Redis and
+// refreshes the global counts with the totals Redis returns. Windows are
+// aligned across replicas, so ended windows are simply forgotten.
+func (s *HybridStore) sync(ctx context.Context) error {
+	now := s.now()
+	s.mu.Lock()
+	var deltas []*hybridDelta
+	for key, counter := range s.counters {
+		if counter.start.Add(counter.window).After(now) {
+			deltas = append(deltas, &hybridDelta{counter: counter, start: counter.start, delta: counter.pending})
+		} else {
+			delete(s.counters, key)
+		}
+	}
+	for key, entry := range s.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(s.cache, key)
+		}
+	}
+	s.mu.Unlock()
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	_, err := s.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, d := range deltas {
+			key := d.counter.key + ":" + strconv.FormatInt(d.start.UnixMilli(), 10)
+			pipe.SetNX(ctx, key, 0, d.counter.window)
+			d.total = pipe.IncrBy(ctx, key, d.delta)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range deltas {
+		d.counter.pending -= d.delta
+		d.counter.global = d.total.Val()
+	}
+	return nil
+}
+
+// counter must be called with the store locked.
+func (s *HybridStore) counter(key string, window time.Duration, now time.Time) *hybridCounter {
+	start := now.Truncate(window)
+	counter, ok := s.counters[key]
+	if !ok || !counter.start.Equal(start) {
+		counter = &hybridCounter{key: key, window: window, start: start}
+		s.counters[key] = counter
+	}
+	return counter
+}
+
+func (s *HybridStore) remember(key, value string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[key] = hybridCacheEntry{value: value, expiresAt: expiresAt}
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridStore_HasReachedLimit(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	limits := []configs.Limit{{Requests: 2, Window: time.Minute}}
+
+	t.Run("decides locally without overshoot", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+		store.now = func() time.Time { return now }
+
+		for i := 0; i < 2; i++ {
+			result, err := store.HasReachedLimit(ctx, "api_key_1", limits)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		result, err := store.HasReachedLimit(ctx, "api_key_1", limits)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, limits[0], result.Limit)
+	})
+
+	t.Run("allows configured overshoot", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0.5)
+		store.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			result, err := store.HasReachedLimit(ctx, "api_key_2", limits)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		result, err := store.HasReachedLimit(ctx, "api_key_2", limits)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("no overshoot over the synced global count", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0.5)
+		store.now = func() time.Time { return now }
+		key := "api_key_4:2/m:" + strconv.FormatInt(now.UnixMilli(), 10)
+
+		result, err := store.HasReachedLimit(ctx, "api_key_4", limits)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+
+		mock.ExpectSetNX(key, 0, time.Minute).SetVal(false)
+		mock.ExpectIncrBy(key, 1).SetVal(2)
+		assert.NoError(t, store.sync(ctx))
+
+		result, err = store.HasReachedLimit(ctx, "api_key_4", limits)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("new window resets local counter", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+		current := now
+		store.now = func() time.Time { return current }
+
+		for i := 0; i < 3; i++ {
+			store.HasReachedLimit(ctx, "api_key_3", limits)
+		}
+
+		current = current.Add(time.Minute)
+		result, err := store.HasReachedLimit(ctx, "api_key_3", limits)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHybridStore_Sync(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	limits := []configs.Limit{{Requests: 6, Window: time.Minute}}
+	key := "api_key_1:6/m:" + strconv.FormatInt(now.UnixMilli(), 10)
+
+	t.Run("flushes deltas and refreshes global count", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+		store.now = func() time.Time { return now }
+
+		store.HasReachedLimit(ctx, "api_key_1", limits)
+		store.HasReachedLimit(ctx, "api_key_1", limits)
+
+		mock.ExpectSetNX(key, 0, time.Minute).SetVal(false)
+		mock.ExpectIncrBy(key, 2).SetVal(5)
+
+		err := store.sync(ctx)
+		assert.NoError(t, err)
+
+		result, err := store.HasReachedLimit(ctx, "api_key_1", limits)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+
+		result, err = store.HasReachedLimit(ctx, "api_key_1", limits)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("keeps deltas when redis fails", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+		store.now = func() time.Time { return now }
+
+		store.HasReachedLimit(ctx, "api_key_1", limits)
+
+		mock.ExpectSetNX(key, 0, time.Minute).SetVal(true)
+		mock.ExpectIncrBy(key, 1).SetErr(redis.ErrClosed)
+
+		err := store.sync(ctx)
+		assert.Error(t, err)
+
+		mock.ExpectSetNX(key, 0, time.Minute).SetVal(true)
+		mock.ExpectIncrBy(key, 1).SetVal(1)
+
+		err = store.sync(ctx)
+		assert.NoError(t, err)
+	})
+
+	t.Run("forgets ended windows", func(t *testing.T) {
+		store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+		current := now
+		store.now = func() time.Time { return current }
+
+		store.HasReachedLimit(ctx, "api_key_1", limits)
+		current = current.Add(time.Minute)
+
+		err := store.sync(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, store.counters)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHybridStore_Get(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	store := newHybridStore(&RateLimiterRepository{RedisClient: db}, time.Second, 0)
+	store.now = func() time.Time { return now }
+
+	t.Run("caches misses until next sync interval", func(t *testing.T) {
+		mock.ExpectGet("blacklist@api_key_1").RedisNil()
+
+		for i := 0; i < 2; i++ {
+			value, err := store.Get(ctx, "blacklist@api_key_1")
+			assert.NoError(t, err)
+			assert.Equal(t, "", value)
+		}
+	})
+
+	t.Run("serves saved values locally", func(t *testing.T) {
+		mock.ExpectSet("blacklist@api_key_2", "Too many requests", 300*time.Second).SetVal("OK")
+
+		err := store.Save(ctx, "blacklist@api_key_2", "Too many requests", 300)
+		assert.NoError(t, err)
+
+		value, err := store.Get(ctx, "blacklist@api_key_2")
+		assert.NoError(t, err)
+		assert.Equal(t, "Too many requests", value)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
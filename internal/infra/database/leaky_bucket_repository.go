@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+var leakyBucketScript = redis.NewScript(`
+local maxQueue = tonumber(ARGV[1])
+local maxWait = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local wait = 0
+for i = 1, #KEYS do
+	local interval = tonumber(ARGV[i + 2])
+	local nextFree = math.max(tonumber(redis.call('GET', KEYS[i])) or now, now)
+	local queued = nextFree - now
+	if queued > 0 and ((maxQueue >= 0 and queued / interval > maxQueue) or queued > maxWait) then
+		return {i, math.ceil(queued)}
+	end
+	wait = math.max(wait, queued)
+end
+
+for i = 1, #KEYS do
+	local interval = tonumber(ARGV[i + 2])
+	local nextFree = now + wait + interval
+	redis.call('SET', KEYS[i], tostring(nextFree), 'PX', math.ceil(nextFree - now))
+end
+return {0, math.ceil(wait)}
+`)
+
+var leakyBucketReleaseScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+for i = 1, #KEYS do
+	local nextFree = tonumber(redis.call('GET', KEYS[i]))
+	if nextFree then
+		nextFree = nextFree - tonumber(ARGV[i])
+		if nextFree > now then
+			redis.call('SET', KEYS[i], tostring(nextFree), 'PX', math.ceil(nextFree - now))
+		else
+			redis.call('DEL', KEYS[i])
+		end
+	end
+end
+return 0
+`)
+
+// DefaultLeakyBucketMaxWait is how long a request may wait for its slot when
+// MaxWait is not set.
+const DefaultLeakyBucketMaxWait = 2 * time.Second
+
+type LeakyBucketRepository struct {
+	*RateLimiterRepository
+	MaxQueue int64
+	MaxWait  time.Duration
+}
+
+// Reserve books the next free slot in the bucket of every limit, each leaking
+// limit.Requests per limit.Window, and returns how long the caller must wait
+// before using them. No slot is booked when a queue is full or the wait would
+// outlive the MaxWait or the caller's deadline, if any. Queues are only
+// bounded by the wait when MaxQueue is not set.
+func (r *LeakyBucketRepository) Reserve(ctx context.Context, apiKey string, limits []configs.Limit, deadline time.Time) (*LimitResult, time.Duration, error) {
+	maxQueue := r.MaxQueue
+	if maxQueue <= 0 {
+		maxQueue = -1
+	}
+	maxWait := r.MaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultLeakyBucketMaxWait
+	}
+	if !deadline.IsZero() && time.Until(deadline) < maxWait {
+		maxWait = time.Until(deadline)
+	}
+	wait, index, err := r.reserve(ctx, apiKey, limits, maxQueue, maxWait)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := reservation(limits, wait, index)
+	if !result.Allowed {
+		return result, 0, nil
+	}
+	return result, wait, nil
+}
+
+// Release gives back a slot booked by Reserve that the caller will not use,
+// so abandoned requests do not hold on to queue capacity.
+func (r *LeakyBucketRepository) Release(ctx context.Context, apiKey string, limits []configs.Limit) error {
+	args := make([]interface{}, 0, len(limits))
+	for _, limit := range limits {
+		args = append(args, float64(limit.Window.Milliseconds())/float64(limit.Requests))
+	}
+	return leakyBucketReleaseScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), args...).Err()
+}
+
+func (r *LeakyBucketRepository) reserve(ctx context.Context, apiKey string, limits []configs.Limit, maxQueue int64, maxWait time.Duration) (time.Duration, int64, error) {
+	for i, limit := range limits {
+		if limit.Requests <= 0 {
+			return 0, int64(i + 1), nil
+		}
+	}
+
+	args := []interface{}{maxQueue, maxWait.Milliseconds()}
+	for _, limit := range limits {
+		args = append(args, float64(limit.Window.Milliseconds())/float64(limit.Requests))
+	}
+
+	reply, err := leakyBucketScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), args...).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(reply) != 2 {
+		return 0, 0, fmt.Errorf("unexpected leaky bucket reply: %v", reply)
+	}
+
+	return time.Duration(reply[1]) * time.Millisecond, reply[0], nil
+}
+
+// HasReachedLimit only accepts requests that can be released right away, so
+// an accepted request leaves no room for another until the slowest bucket
+// has leaked it.
+func (r *LeakyBucketRepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	queued, index, err := r.reserve(ctx, apiKey, limits, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return reservation(limits, queued, index), nil
+}
+
+// reservation describes the reply of reserve. The 1-based index is the limit
+// whose queue had no room for another wait, or 0 when the request got its
+// slots, in which case the slowest bucket is the one reported.
+func reservation(limits []configs.Limit, wait time.Duration, index int64) *LimitResult {
+	if index > 0 && index <= int64(len(limits)) {
+		limit := limits[index-1]
+		if wait == 0 {
+			wait = limit.Window
+		}
+		return &LimitResult{Allowed: false, Limit: limit, RetryAfter: wait, ResetAfter: wait}
+	}
+
+	result := &LimitResult{Allowed: true}
+	for _, limit := range limits {
+		interval := limit.Window / time.Duration(limit.Requests)
+		if result.Limit.Window == 0 || wait+interval > result.ResetAfter {
+			result.Limit, result.ResetAfter = limit, wait+interval
+		}
+	}
+	return result
+}
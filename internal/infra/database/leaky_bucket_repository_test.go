@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeakyBucketRepository_Reserve(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &LeakyBucketRepository{
+		RateLimiterRepository: &RateLimiterRepository{RedisClient: db},
+		MaxQueue:              10,
+		MaxWait:               2 * time.Second,
+	}
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perMinute := configs.Limit{Requests: 60, Window: time.Minute}
+
+	t.Run("released immediately", func(t *testing.T) {
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(10), int64(2000), float64(200)).SetVal([]interface{}{int64(0), int64(0)})
+
+		result, wait, err := repo.Reserve(ctx, apiKey, []configs.Limit{perSecond}, time.Time{})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, 200*time.Millisecond, result.ResetAfter)
+		assert.Equal(t, time.Duration(0), wait)
+	})
+
+	t.Run("queued", func(t *testing.T) {
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"},
+			int64(10), int64(2000), float64(200), float64(1000)).SetVal([]interface{}{int64(0), int64(600)})
+
+		result, wait, err := repo.Reserve(ctx, apiKey, []configs.Limit{perSecond, perMinute}, time.Time{})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perMinute, result.Limit)
+		assert.Equal(t, 1600*time.Millisecond, result.ResetAfter)
+		assert.Equal(t, 600*time.Millisecond, wait)
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		apiKey := "api_key_3"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(10), int64(2000), float64(200)).SetVal([]interface{}{int64(1), int64(2200)})
+
+		result, _, err := repo.Reserve(ctx, apiKey, []configs.Limit{perSecond}, time.Time{})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, 2200*time.Millisecond, result.RetryAfter)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		apiKey := "api_key_4"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(10), int64(2000), float64(200)).SetErr(redis.ErrClosed)
+
+		result, _, err := repo.Reserve(ctx, apiKey, []configs.Limit{perSecond}, time.Time{})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLeakyBucketRepository_ReserveDefaults(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &LeakyBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+	ctx := context.Background()
+	apiKey := "api_key_1"
+
+	mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(-1), int64(2000), float64(200)).SetVal([]interface{}{int64(0), int64(400)})
+
+	result, wait, err := repo.Reserve(ctx, apiKey, []configs.Limit{{Requests: 5, Window: time.Second}}, time.Time{})
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 400*time.Millisecond, wait)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLeakyBucketRepository_Release(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &LeakyBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+	ctx := context.Background()
+	limits := []configs.Limit{{Requests: 5, Window: time.Second}, {Requests: 60, Window: time.Minute}}
+
+	t.Run("released", func(t *testing.T) {
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(leakyBucketReleaseScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"}, float64(200), float64(1000)).SetVal(int64(0))
+
+		assert.NoError(t, repo.Release(ctx, apiKey, limits))
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(leakyBucketReleaseScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"}, float64(200), float64(1000)).SetErr(redis.ErrClosed)
+
+		assert.Error(t, repo.Release(ctx, apiKey, limits))
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLeakyBucketRepository_HasReachedLimit(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &LeakyBucketRepository{
+		RateLimiterRepository: &RateLimiterRepository{RedisClient: db},
+		MaxQueue:              10,
+		MaxWait:               2 * time.Second,
+	}
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perMinute := configs.Limit{Requests: 60, Window: time.Minute}
+
+	t.Run("request would have to wait", func(t *testing.T) {
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"},
+			int64(0), int64(0), float64(200), float64(1000)).SetVal([]interface{}{int64(2), int64(400)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perMinute})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perMinute, result.Limit)
+		assert.Equal(t, 400*time.Millisecond, result.RetryAfter)
+	})
+
+	t.Run("request released right away", func(t *testing.T) {
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(leakyBucketScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"},
+			int64(0), int64(0), float64(200), float64(1000)).SetVal([]interface{}{int64(0), int64(0)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perMinute})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perMinute, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, time.Second, result.ResetAfter)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+)
+
+const memoryShards = 32
+
+type memoryEntry struct {
+	value     string
+	count     int64
+	expiresAt time.Time
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// MemoryStore keeps blacklist entries and fixed window counters in process,
+// for single instance deployments and tests that should not need Redis.
+type MemoryStore struct {
+	shards []*memoryShard
+	done   chan struct{}
+	once   sync.Once
+	now    func() time.Time
+}
+
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		shards: make([]*memoryShard, memoryShards),
+		done:   make(chan struct{}),
+		now:    time.Now,
+	}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+	if cleanupInterval > 0 {
+		go s.janitor(cleanupInterval)
+	}
+	return s
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry := shard.get(key, s.now())
+	if entry == nil {
+		return "", nil
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, key, value string, ttl int64) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.entries[key] = &memoryEntry{value: value, expiresAt: s.now().Add(time.Duration(ttl) * time.Second)}
+	return nil
+}
+
+// TTL returns how long key has left to live, or 0 when it does not exist.
+func (s *MemoryStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := s.now()
+	entry := shard.get(key, now)
+	if entry == nil {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(now), nil
+}
+
+// HasReachedLimit counts the request against a fixed window per limit. The
+// counters of one identity share a shard so they are updated under one lock.
+func (s *MemoryStore) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	shard := s.shard(apiKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := s.now()
+	var tripped *LimitResult
+	result := &LimitResult{Allowed: true}
+	for i, key := range limitKeys(apiKey, limits) {
+		entry := shard.get(key, now)
+		if entry == nil {
+			entry = &memoryEntry{expiresAt: now.Add(limits[i].Window)}
+			shard.entries[key] = entry
+		}
+		entry.count++
+		resetAfter := entry.expiresAt.Sub(now)
+		if tripped == nil && entry.count > limits[i].Requests {
+			tripped = &LimitResult{Allowed: false, Limit: limits[i], RetryAfter: resetAfter, ResetAfter: resetAfter}
+		}
+		result.tighten(limits[i], limits[i].Requests-entry.count, resetAfter)
+	}
+	if tripped != nil {
+		return tripped, nil
+	}
+	return result, nil
+}
+
+// Close stops the background janitor.
+func (s *MemoryStore) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) deleteExpired() {
+	now := s.now()
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// get must be called with the shard locked.
+func (shard *memoryShard) get(key string, now time.Time) *memoryEntry {
+	entry, ok := shard.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(shard.entries, key)
+		return nil
+	}
+	return entry
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMemoryStore(now *time.Time) *MemoryStore {
+	store := NewMemoryStore(0)
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestMemoryStore_GetAndSave(t *testing.T) {
+	now := time.Now()
+	store := newTestMemoryStore(&now)
+	ctx := context.Background()
+
+	t.Run("key does not exist", func(t *testing.T) {
+		value, err := store.Get(ctx, "non_existing_key")
+		assert.NoError(t, err)
+		assert.Equal(t, "", value)
+	})
+
+	t.Run("key exists", func(t *testing.T) {
+		err := store.Save(ctx, "key_to_save", "value_to_save", 60)
+		assert.NoError(t, err)
+
+		value, err := store.Get(ctx, "key_to_save")
+		assert.NoError(t, err)
+		assert.Equal(t, "value_to_save", value)
+	})
+
+	t.Run("key expired", func(t *testing.T) {
+		err := store.Save(ctx, "key_to_expire", "value_to_save", 60)
+		assert.NoError(t, err)
+
+		now = now.Add(61 * time.Second)
+		value, err := store.Get(ctx, "key_to_expire")
+		assert.NoError(t, err)
+		assert.Equal(t, "", value)
+	})
+}
+
+func TestMemoryStore_TTL(t *testing.T) {
+	now := time.Now()
+	store := newTestMemoryStore(&now)
+	ctx := context.Background()
+
+	t.Run("key does not exist", func(t *testing.T) {
+		ttl, err := store.TTL(ctx, "non_existing_key")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), ttl)
+	})
+
+	t.Run("key exists", func(t *testing.T) {
+		err := store.Save(ctx, "blacklisted_key", "Too many requests", 60)
+		assert.NoError(t, err)
+
+		now = now.Add(20 * time.Second)
+		ttl, err := store.TTL(ctx, "blacklisted_key")
+		assert.NoError(t, err)
+		assert.Equal(t, 40*time.Second, ttl)
+	})
+}
+
+func TestMemoryStore_HasReachedLimit(t *testing.T) {
+	now := time.Now()
+	store := newTestMemoryStore(&now)
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 2, Window: time.Second}
+	perHour := configs.Limit{Requests: 3, Window: time.Hour}
+
+	t.Run("requests within limit", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			result, err := store.HasReachedLimit(ctx, "api_key_1", []configs.Limit{perSecond})
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, int64(1-i), result.Remaining)
+			assert.Equal(t, time.Second, result.ResetAfter)
+		}
+	})
+
+	t.Run("request exceeds limit", func(t *testing.T) {
+		result, err := store.HasReachedLimit(ctx, "api_key_1", []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, time.Second, result.RetryAfter)
+	})
+
+	t.Run("window expires", func(t *testing.T) {
+		now = now.Add(time.Second)
+		result, err := store.HasReachedLimit(ctx, "api_key_1", []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("sustained limit exceeded", func(t *testing.T) {
+		limits := []configs.Limit{perSecond, perHour}
+		for i := 0; i < 3; i++ {
+			now = now.Add(time.Second)
+			result, err := store.HasReachedLimit(ctx, "api_key_2", limits)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		now = now.Add(time.Second)
+		result, err := store.HasReachedLimit(ctx, "api_key_2", limits)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perHour, result.Limit)
+	})
+}
+
+func TestMemoryStore_Concurrency(t *testing.T) {
+	store := NewMemoryStore(time.Millisecond)
+	defer store.Close()
+	ctx := context.Background()
+	limits := []configs.Limit{{Requests: 100, Window: time.Hour}}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := store.HasReachedLimit(ctx, "api_key", limits)
+			assert.NoError(t, err)
+			store.Save(ctx, fmt.Sprintf("key_%d", i), "value", 1)
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, allowed)
+}
+
+func TestMemoryStore_DeleteExpired(t *testing.T) {
+	now := time.Now()
+	store := newTestMemoryStore(&now)
+	ctx := context.Background()
+
+	store.Save(ctx, "short_key", "value", 1)
+	store.Save(ctx, "long_key", "value", 60)
+
+	now = now.Add(2 * time.Second)
+	store.deleteExpired()
+
+	entries := 0
+	for _, shard := range store.shards {
+		entries += len(shard.entries)
+	}
+	assert.Equal(t, 1, entries)
+}
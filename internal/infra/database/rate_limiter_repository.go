@@ -2,45 +2,109 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/carlosmeds/rate-limiter/configs"
 	"github.com/redis/go-redis/v9"
 )
 
+type LimitResult struct {
+	Allowed     bool
+	BlackListed bool
+	Limit       configs.Limit
+	Remaining   int64
+	RetryAfter  time.Duration
+	ResetAfter  time.Duration
+}
+
 type RateLimiterRepository struct {
-	RedisClient *redis.Client
+	RedisClient redis.UniversalClient
+	Breaker     *CircuitBreaker
 }
 
 func NewRateLimiterRepository() *RateLimiterRepository {
-	redisAddr := configs.GetConfig().RedisAddr
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
+	config := configs.GetConfig()
+	redisClient := newRedisClient(config)
+	repo := &RateLimiterRepository{RedisClient: redisClient}
+	if config.BreakerFailureThreshold > 0 {
+		repo.Breaker = NewCircuitBreaker(config.BreakerFailureThreshold, config.BreakerCooldown, config.BreakerHalfOpenRequests)
+		redisClient.AddHook(repo.Breaker)
 	}
+	return repo
+}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
-	return &RateLimiterRepository{RedisClient: redisClient}
+// newRedisClient connects to a single Redis server, to the master of a
+// Sentinel deployment or to a Redis Cluster, depending on REDIS_MODE.
+func newRedisClient(config *configs.Config) redis.UniversalClient {
+	options := redisOptions(config)
+	switch config.RedisMode {
+	case configs.RedisSentinel:
+		return redis.NewFailoverClient(options.Failover())
+	case configs.RedisCluster:
+		return redis.NewClusterClient(options.Cluster())
+	default:
+		return redis.NewClient(options.Simple())
+	}
+}
+
+func redisOptions(config *configs.Config) *redis.UniversalOptions {
+	var redisAddrs []string
+	for _, addr := range strings.Split(config.RedisAddr, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			redisAddrs = append(redisAddrs, addr)
+		}
+	}
+	if len(redisAddrs) == 0 {
+		redisAddrs = []string{"localhost:6379"}
+	}
+
+	options := &redis.UniversalOptions{
+		Addrs:                 redisAddrs,
+		MasterName:            config.RedisMasterName,
+		Username:              config.RedisUsername,
+		Password:              config.RedisPassword,
+		SentinelPassword:      config.RedisSentinelPassword,
+		DB:                    config.RedisDB,
+		PoolSize:              config.RedisPoolSize,
+		MinIdleConns:          config.RedisMinIdleConns,
+		DialTimeout:           config.RedisDialTimeout,
+		ReadTimeout:           config.RedisReadTimeout,
+		WriteTimeout:          config.RedisWriteTimeout,
+		PoolTimeout:           config.RedisPoolTimeout,
+		ContextTimeoutEnabled: true,
+	}
+	if config.RedisTLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return options
+}
+
+// CircuitOpen reports whether the circuit breaker is currently short
+// circuiting every call to Redis.
+func (r *RateLimiterRepository) CircuitOpen() bool {
+	return r.Breaker != nil && r.Breaker.State() == CircuitOpen
+}
+
+// Repository returns the connection shared by the repositories built on r,
+// so strategies of other algorithms can reuse its client and breaker.
+func (r *RateLimiterRepository) Repository() *RateLimiterRepository {
+	return r
 }
 
 func (r *RateLimiterRepository) Get(ctx context.Context, key string) (string, error) {
-	fmt.Println("Getting key", key)
 	value, err := r.RedisClient.Get(ctx, key).Result()
 	if err == redis.Nil {
-		log.Println("Key not found")
 		return "", nil
 	} else if err != nil {
-		fmt.Println("Error", key, err)
 		return "", err
 	}
 	return value, nil
 }
 
 func (r *RateLimiterRepository) Save(ctx context.Context, key, value string, ttl int64) error {
-	fmt.Println("Saving", value, "for", key)
 	err := r.RedisClient.Set(ctx, key, value, time.Duration(ttl)*time.Second).Err()
 	if err != nil {
 		return err
@@ -48,22 +112,122 @@ func (r *RateLimiterRepository) Save(ctx context.Context, key, value string, ttl
 	return nil
 }
 
-func (r *RateLimiterRepository) HasReachedLimit(ctx context.Context, apiKey string, limit int64) (bool, error) {
-	count, err := r.RedisClient.Incr(ctx, apiKey).Result()
+// TTL returns how long key has left to live, or 0 when it does not expire or
+// does not exist.
+func (r *RateLimiterRepository) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.RedisClient.PTTL(ctx, key).Result()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
+	return max(0, ttl), nil
+}
 
-	fmt.Println("Count", count)
-	if count == 1 {
-		err = r.RedisClient.Expire(ctx, apiKey, 1*time.Second).Err()
-		if err != nil {
-			return true, err
+// HasReachedLimit counts the request against every limit in a single
+// transaction and reports the first limit that has been exceeded or, when
+// none has, the one with the fewest requests remaining.
+func (r *RateLimiterRepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	keys := limitKeys(apiKey, limits)
+	counts := make([]*redis.IntCmd, len(limits))
+	ttls := make([]*redis.DurationCmd, len(limits))
+	_, err := r.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, limit := range limits {
+			pipe.SetNX(ctx, keys[i], 0, limit.Window)
+			counts[i] = pipe.Incr(ctx, keys[i])
+			ttls[i] = pipe.PTTL(ctx, keys[i])
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if count > limit {
-		return true, nil
+	result := &LimitResult{Allowed: true}
+	for i, limit := range limits {
+		ttl := ttls[i].Val()
+		if ttl < 0 {
+			ttl = limit.Window
+		}
+		if counts[i].Val() > limit.Requests {
+			return &LimitResult{Allowed: false, Limit: limit, RetryAfter: ttl, ResetAfter: ttl}, nil
+		}
+		result.tighten(limit, limit.Requests-counts[i].Val(), ttl)
+	}
+	return result, nil
+}
+
+// tighten reports limit in r when it has fewer requests remaining than the
+// limit reported so far.
+func (r *LimitResult) tighten(limit configs.Limit, remaining int64, resetAfter time.Duration) {
+	if r.Limit.Window == 0 || remaining < r.Remaining {
+		r.Limit, r.Remaining, r.ResetAfter = limit, remaining, resetAfter
+	}
+}
+
+// BlackListValue is stored in the blacklist key of a client blocked for
+// reaching limit, so later requests can tell which limit it was.
+func BlackListValue(limit configs.Limit) string {
+	return fmt.Sprintf("Too many requests: %d/%dms", limit.Requests, limit.Window.Milliseconds())
+}
+
+// BlackListedResult describes a client blacklisted with value for another
+// blockedFor. The limit named by value is reported as exhausted until the
+// block is over, or the first of limits when value names none of them.
+func BlackListedResult(value string, limits []configs.Limit, blockedFor time.Duration) *LimitResult {
+	for i, limit := range limits {
+		if BlackListValue(limit) == value {
+			return blackListedResult(limits, i, blockedFor)
+		}
+	}
+	return blackListedResult(limits, 0, blockedFor)
+}
+
+func blackListedResult(limits []configs.Limit, index int, blockedFor time.Duration) *LimitResult {
+	blockedFor = max(0, blockedFor)
+	result := &LimitResult{Allowed: false, BlackListed: true, RetryAfter: blockedFor, ResetAfter: blockedFor}
+	if index < 0 || index >= len(limits) {
+		index = 0
+	}
+	if len(limits) > 0 {
+		result.Limit = limits[index]
+	}
+	return result
+}
+
+func limitKeys(apiKey string, limits []configs.Limit) []string {
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = apiKey + ":" + limit.String()
+	}
+	return keys
+}
+
+// scriptResult reads the {tripped, index, remaining, retry after, reset
+// after} reply of the Lua scripts, with durations in milliseconds. The 1-based
+// index is the limit that tripped or, when none did, the one with the fewest
+// requests remaining, and 0 when there are no limits.
+func scriptResult(limits []configs.Limit, reply []int64) (*LimitResult, error) {
+	if len(reply) != 5 || reply[1] < 0 || reply[1] > int64(len(limits)) {
+		return nil, fmt.Errorf("unexpected script reply: %v", reply)
+	}
+	result := &LimitResult{
+		Allowed:    reply[0] == 0,
+		Remaining:  reply[2],
+		RetryAfter: time.Duration(reply[3]) * time.Millisecond,
+		ResetAfter: time.Duration(reply[4]) * time.Millisecond,
+	}
+	if reply[1] > 0 {
+		result.Limit = limits[reply[1]-1]
+	}
+	return result, nil
+}
+
+// blockedLimit returns the first limit that allows no requests at all, which
+// the rate based scripts cannot express.
+func blockedLimit(limits []configs.Limit) (configs.Limit, bool) {
+	for _, limit := range limits {
+		if limit.Requests <= 0 {
+			return limit, true
+		}
 	}
-	return false, nil
+	return configs.Limit{}, false
 }
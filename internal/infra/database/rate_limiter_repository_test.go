@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
 	"testing"
 	"time"
 
+	"github.com/carlosmeds/rate-limiter/configs"
 	"github.com/go-redis/redismock/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -80,66 +82,127 @@ func TestRateLimiterRepository_Save(t *testing.T) {
 	}
 }
 
+func TestRateLimiterRepository_TTL(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &RateLimiterRepository{RedisClient: db}
+	ctx := context.Background()
+
+	t.Run("key expires", func(t *testing.T) {
+		mock.ExpectPTTL("blacklist@{api_key_1}").SetVal(90 * time.Second)
+
+		ttl, err := repo.TTL(ctx, "blacklist@{api_key_1}")
+		assert.NoError(t, err)
+		assert.Equal(t, 90*time.Second, ttl)
+	})
+
+	t.Run("key does not exist", func(t *testing.T) {
+		mock.ExpectPTTL("blacklist@{api_key_2}").SetVal(-2)
+
+		ttl, err := repo.TTL(ctx, "blacklist@{api_key_2}")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), ttl)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		mock.ExpectPTTL("blacklist@{api_key_3}").SetErr(redis.ErrClosed)
+
+		_, err := repo.TTL(ctx, "blacklist@{api_key_3}")
+		assert.Error(t, err)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestRateLimiterRepository_HasReachedLimit(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	repo := &RateLimiterRepository{RedisClient: db}
 	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perHour := configs.Limit{Requests: 100, Window: time.Hour}
 
 	t.Run("first request within limit", func(t *testing.T) {
 		apiKey := "api_key_1"
-		limit := int64(5)
 
-		mock.ExpectIncr(apiKey).SetVal(1)
-		mock.ExpectExpire(apiKey, 1*time.Second).SetVal(true)
+		mock.ExpectTxPipeline()
+		mock.ExpectSetNX(apiKey+":5/s", 0, time.Second).SetVal(true)
+		mock.ExpectIncr(apiKey + ":5/s").SetVal(1)
+		mock.ExpectPTTL(apiKey + ":5/s").SetVal(time.Second)
+		mock.ExpectTxPipelineExec()
 
-		reachedLimit, err := repo.HasReachedLimit(ctx, apiKey, limit)
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
 		assert.NoError(t, err)
-		assert.False(t, reachedLimit)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(4), result.Remaining)
+		assert.Equal(t, time.Second, result.ResetAfter)
 	})
 
-	t.Run("subsequent request within limit", func(t *testing.T) {
+	t.Run("request exceeds limit", func(t *testing.T) {
 		apiKey := "api_key_2"
-		limit := int64(5)
 
-		mock.ExpectIncr(apiKey).SetVal(3)
+		mock.ExpectTxPipeline()
+		mock.ExpectSetNX(apiKey+":5/s", 0, time.Second).SetVal(false)
+		mock.ExpectIncr(apiKey + ":5/s").SetVal(6)
+		mock.ExpectPTTL(apiKey + ":5/s").SetVal(400 * time.Millisecond)
+		mock.ExpectTxPipelineExec()
 
-		reachedLimit, err := repo.HasReachedLimit(ctx, apiKey, limit)
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
 		assert.NoError(t, err)
-		assert.False(t, reachedLimit)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, 400*time.Millisecond, result.RetryAfter)
 	})
 
-	t.Run("request exceeds limit", func(t *testing.T) {
+	t.Run("multiple limits within limit", func(t *testing.T) {
 		apiKey := "api_key_3"
-		limit := int64(5)
 
-		mock.ExpectIncr(apiKey).SetVal(6)
+		mock.ExpectTxPipeline()
+		mock.ExpectSetNX(apiKey+":5/s", 0, time.Second).SetVal(false)
+		mock.ExpectIncr(apiKey + ":5/s").SetVal(3)
+		mock.ExpectPTTL(apiKey + ":5/s").SetVal(500 * time.Millisecond)
+		mock.ExpectSetNX(apiKey+":100/h", 0, time.Hour).SetVal(false)
+		mock.ExpectIncr(apiKey + ":100/h").SetVal(99)
+		mock.ExpectPTTL(apiKey + ":100/h").SetVal(time.Minute)
+		mock.ExpectTxPipelineExec()
 
-		reachedLimit, err := repo.HasReachedLimit(ctx, apiKey, limit)
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perHour})
 		assert.NoError(t, err)
-		assert.True(t, reachedLimit)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perHour, result.Limit)
+		assert.Equal(t, int64(1), result.Remaining)
+		assert.Equal(t, time.Minute, result.ResetAfter)
 	})
 
-	t.Run("redis error on incr", func(t *testing.T) {
+	t.Run("sustained limit exceeded", func(t *testing.T) {
 		apiKey := "api_key_4"
-		limit := int64(5)
 
-		mock.ExpectIncr(apiKey).SetErr(redis.ErrClosed)
+		mock.ExpectTxPipeline()
+		mock.ExpectSetNX(apiKey+":5/s", 0, time.Second).SetVal(true)
+		mock.ExpectIncr(apiKey + ":5/s").SetVal(1)
+		mock.ExpectPTTL(apiKey + ":5/s").SetVal(time.Second)
+		mock.ExpectSetNX(apiKey+":100/h", 0, time.Hour).SetVal(false)
+		mock.ExpectIncr(apiKey + ":100/h").SetVal(101)
+		mock.ExpectPTTL(apiKey + ":100/h").SetVal(time.Minute)
+		mock.ExpectTxPipelineExec()
 
-		reachedLimit, err := repo.HasReachedLimit(ctx, apiKey, limit)
-		assert.Error(t, err)
-		assert.False(t, reachedLimit)
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perHour})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perHour, result.Limit)
+		assert.Equal(t, time.Minute, result.RetryAfter)
 	})
 
-	t.Run("redis error on expire", func(t *testing.T) {
+	t.Run("redis error", func(t *testing.T) {
 		apiKey := "api_key_5"
-		limit := int64(5)
 
-		mock.ExpectIncr(apiKey).SetVal(1)
-		mock.ExpectExpire(apiKey, 1*time.Second).SetErr(redis.ErrClosed)
+		mock.ExpectTxPipeline()
+		mock.ExpectSetNX(apiKey+":5/s", 0, time.Second).SetErr(redis.ErrClosed)
 
-		reachedLimit, err := repo.HasReachedLimit(ctx, apiKey, limit)
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
 		assert.Error(t, err)
-		assert.True(t, reachedLimit)
+		assert.Nil(t, result)
 	})
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -147,3 +210,76 @@ func TestRateLimiterRepository_HasReachedLimit(t *testing.T) {
 	}
 }
 
+func TestBlackListedResult(t *testing.T) {
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perHour := configs.Limit{Requests: 100, Window: time.Hour}
+	limits := []configs.Limit{perSecond, perHour}
+
+	t.Run("tripped limit", func(t *testing.T) {
+		result := BlackListedResult(BlackListValue(perHour), limits, time.Minute)
+		assert.False(t, result.Allowed)
+		assert.True(t, result.BlackListed)
+		assert.Equal(t, perHour, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, time.Minute, result.RetryAfter)
+		assert.Equal(t, time.Minute, result.ResetAfter)
+	})
+
+	t.Run("unknown limit", func(t *testing.T) {
+		result := BlackListedResult("Too many requests", limits, time.Minute)
+		assert.True(t, result.BlackListed)
+		assert.Equal(t, perSecond, result.Limit)
+	})
+}
+
+func TestRedisOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		options := redisOptions(&configs.Config{})
+		assert.Equal(t, []string{"localhost:6379"}, options.Addrs)
+		assert.True(t, options.ContextTimeoutEnabled)
+		assert.Nil(t, options.TLSConfig)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		options := redisOptions(&configs.Config{
+			RedisAddr:         "redis-1:26379, redis-2:26379",
+			RedisMasterName:   "mymaster",
+			RedisUsername:     "user",
+			RedisPassword:     "secret",
+			RedisDB:           2,
+			RedisTLS:          true,
+			RedisPoolSize:     20,
+			RedisMinIdleConns: 5,
+			RedisReadTimeout:  50 * time.Millisecond,
+		})
+		assert.Equal(t, []string{"redis-1:26379", "redis-2:26379"}, options.Addrs)
+		assert.Equal(t, "mymaster", options.MasterName)
+		assert.Equal(t, "user", options.Username)
+		assert.Equal(t, "secret", options.Password)
+		assert.Equal(t, 2, options.DB)
+		assert.Equal(t, 20, options.PoolSize)
+		assert.Equal(t, 5, options.MinIdleConns)
+		assert.Equal(t, 50*time.Millisecond, options.ReadTimeout)
+		assert.Equal(t, uint16(tls.VersionTLS12), options.TLSConfig.MinVersion)
+	})
+}
+
+func TestNewRedisClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *configs.Config
+		expected redis.UniversalClient
+	}{
+		{"single", &configs.Config{RedisAddr: "redis:6379"}, &redis.Client{}},
+		{"sentinel", &configs.Config{RedisMode: configs.RedisSentinel, RedisAddr: "sentinel:26379", RedisMasterName: "mymaster"}, &redis.Client{}},
+		{"cluster", &configs.Config{RedisMode: configs.RedisCluster, RedisAddr: "node-1:6379,node-2:6379"}, &redis.ClusterClient{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newRedisClient(tt.config)
+			defer client.Close()
+			assert.IsType(t, tt.expected, client)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+var slidingWindowCounterScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local windows = {}
+local reply = {0, 0, 0, 0, 0}
+for i = 1, #KEYS do
+	local limit = tonumber(ARGV[i * 2 - 1])
+	local window = tonumber(ARGV[i * 2])
+	local index = math.floor(now / window)
+
+	local counters = redis.call('HMGET', KEYS[i], 'index', 'current', 'previous')
+	local current = tonumber(counters[2]) or 0
+	local previous = tonumber(counters[3]) or 0
+	local stored = tonumber(counters[1])
+	if stored == index - 1 then
+		previous = current
+		current = 0
+	elseif stored ~= index then
+		previous = 0
+		current = 0
+	end
+
+	local elapsed = (now - index * window) / window
+	local resetAfter = (index + 1) * window - now
+	local estimate = previous * (1 - elapsed) + current
+	if estimate >= limit then
+		-- the estimate drops below the limit either as the previous window
+		-- slides out of this one or, once this window is full, of the next
+		local retryAfter = resetAfter
+		if current < limit then
+			retryAfter = window * (1 - (limit - current) / previous) - (now - index * window)
+		elseif limit > 0 then
+			retryAfter = resetAfter + window * (1 - limit / current)
+		end
+		return {1, i, 0, math.ceil(retryAfter), math.ceil(math.max(retryAfter, resetAfter))}
+	end
+	windows[i] = {index, current, previous}
+
+	local remaining = math.max(0, math.floor(limit - estimate - 1))
+	if reply[2] == 0 or remaining < reply[3] then
+		reply = {0, i, remaining, 0, resetAfter}
+	end
+end
+
+for i = 1, #KEYS do
+	local w = windows[i]
+	redis.call('HSET', KEYS[i], 'index', w[1], 'current', w[2] + 1, 'previous', w[3])
+	redis.call('PEXPIRE', KEYS[i], tonumber(ARGV[i * 2]) * 2)
+end
+return reply
+`)
+
+type SlidingWindowCounterRepository struct {
+	*RateLimiterRepository
+}
+
+// HasReachedLimit estimates the requests in each sliding window by weighting
+// the previous fixed window by how much of it still overlaps the current one.
+func (r *SlidingWindowCounterRepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	reply, err := slidingWindowCounterScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), windowArgs(limits)...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return scriptResult(limits, reply)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+var slidingWindowLogScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local reply = {0, 0, 0, 0, 0}
+for i = 1, #KEYS do
+	local limit = tonumber(ARGV[i * 2 - 1])
+	local window = tonumber(ARGV[i * 2])
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now - window)
+	local count = redis.call('ZCARD', KEYS[i])
+	if count >= limit then
+		local retryAfter, resetAfter = window, window
+		if count > 0 then
+			local oldest = redis.call('ZRANGE', KEYS[i], math.min(count - limit, count - 1), math.min(count - limit, count - 1), 'WITHSCORES')
+			local newest = redis.call('ZRANGE', KEYS[i], -1, -1, 'WITHSCORES')
+			retryAfter = tonumber(oldest[2]) + window - now
+			resetAfter = tonumber(newest[2]) + window - now
+		end
+		return {1, i, 0, retryAfter, resetAfter}
+	end
+	if reply[2] == 0 or limit - count - 1 < reply[3] then
+		reply = {0, i, limit - count - 1, 0, window}
+	end
+end
+
+for i = 1, #KEYS do
+	local member = time[1] .. ':' .. time[2] .. ':' .. redis.call('ZCARD', KEYS[i])
+	redis.call('ZADD', KEYS[i], now, member)
+	redis.call('PEXPIRE', KEYS[i], ARGV[i * 2])
+end
+return reply
+`)
+
+type SlidingWindowLogRepository struct {
+	*RateLimiterRepository
+}
+
+// HasReachedLimit keeps the timestamp of every accepted request in a sorted
+// set per limit and only accepts a new one while each set holds fewer than
+// limit.Requests entries within the last limit.Window.
+func (r *SlidingWindowLogRepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	reply, err := slidingWindowLogScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), windowArgs(limits)...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return scriptResult(limits, reply)
+}
+
+func windowArgs(limits []configs.Limit) []interface{} {
+	args := make([]interface{}, 0, len(limits)*2)
+	for _, limit := range limits {
+		args = append(args, limit.Requests, limit.Window.Milliseconds())
+	}
+	return args
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlidingWindowLogRepository_HasReachedLimit(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := &SlidingWindowLogRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perMinute := configs.Limit{Requests: 100, Window: time.Minute}
+
+	t.Run("request within limit", func(t *testing.T) {
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(slidingWindowLogScript.Hash(), []string{apiKey + ":5/s"}, int64(5), int64(1000)).
+			SetVal([]interface{}{int64(0), int64(1), int64(4), int64(0), int64(1000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(4), result.Remaining)
+	})
+
+	t.Run("request exceeds limit", func(t *testing.T) {
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(slidingWindowLogScript.Hash(), []string{apiKey + ":5/s"}, int64(5), int64(1000)).
+			SetVal([]interface{}{int64(1), int64(1), int64(0), int64(300), int64(900)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+	})
+
+	t.Run("request exceeds minute limit", func(t *testing.T) {
+		apiKey := "api_key_3"
+
+		mock.ExpectEvalSha(slidingWindowLogScript.Hash(), []string{apiKey + ":5/s", apiKey + ":100/m"},
+			int64(5), int64(1000), int64(100), int64(60000)).
+			SetVal([]interface{}{int64(1), int64(2), int64(0), int64(20000), int64(59000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perMinute})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perMinute, result.Limit)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		apiKey := "api_key_4"
+
+		mock.ExpectEvalSha(slidingWindowLogScript.Hash(), []string{apiKey + ":5/s"}, int64(5), int64(1000)).SetErr(redis.ErrClosed)
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/redis/go-redis/v9"
+)
+
+var tokenBucketScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local buckets = {}
+local reply = {0, 0, 0, 0, 0}
+for i = 1, #KEYS do
+	local capacity = tonumber(ARGV[i * 2 - 1])
+	local rate = tonumber(ARGV[i * 2])
+	local bucket = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+	local tokens = tonumber(bucket[1])
+	local ts = tonumber(bucket[2])
+	if tokens == nil or ts == nil then
+		tokens = capacity
+		ts = now
+	end
+
+	tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate / 1000)
+	if tokens < 1 then
+		return {1, i, 0, math.ceil((1 - tokens) * 1000 / rate), math.ceil((capacity - tokens) * 1000 / rate)}
+	end
+	buckets[i] = tokens
+
+	local remaining = math.floor(tokens - 1)
+	if reply[2] == 0 or remaining < reply[3] then
+		reply = {0, i, remaining, 0, math.ceil((capacity - tokens + 1) * 1000 / rate)}
+	end
+end
+
+for i = 1, #KEYS do
+	local capacity = tonumber(ARGV[i * 2 - 1])
+	local rate = tonumber(ARGV[i * 2])
+	redis.call('HSET', KEYS[i], 'tokens', tostring(buckets[i] - 1), 'ts', now)
+	redis.call('PEXPIRE', KEYS[i], math.ceil(capacity / rate * 1000))
+end
+return reply
+`)
+
+type TokenBucketRepository struct {
+	*RateLimiterRepository
+}
+
+// HasReachedLimit takes one token from the bucket of every limit, or none if
+// any bucket is empty. Each bucket holds limit.Requests tokens and refills
+// them all over limit.Window, so "20/40s" bursts 20 requests and then allows
+// one every two seconds.
+func (r *TokenBucketRepository) HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*LimitResult, error) {
+	if limit, blocked := blockedLimit(limits); blocked {
+		return &LimitResult{Allowed: false, Limit: limit, RetryAfter: limit.Window, ResetAfter: limit.Window}, nil
+	}
+
+	args := make([]interface{}, 0, len(limits)*2)
+	for _, limit := range limits {
+		args = append(args, limit.Requests, float64(limit.Requests)/limit.Window.Seconds())
+	}
+
+	reply, err := tokenBucketScript.Run(ctx, r.RedisClient, limitKeys(apiKey, limits), args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return scriptResult(limits, reply)
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketRepository_HasReachedLimit(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	ctx := context.Background()
+	perSecond := configs.Limit{Requests: 5, Window: time.Second}
+	perMinute := configs.Limit{Requests: 60, Window: time.Minute}
+
+	t.Run("token available", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		apiKey := "api_key_1"
+
+		mock.ExpectEvalSha(tokenBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(5)).
+			SetVal([]interface{}{int64(0), int64(1), int64(4), int64(0), int64(200)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(4), result.Remaining)
+	})
+
+	t.Run("bucket empty", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		apiKey := "api_key_2"
+
+		mock.ExpectEvalSha(tokenBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(5)).
+			SetVal([]interface{}{int64(1), int64(1), int64(0), int64(200), int64(1000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perSecond, result.Limit)
+		assert.Equal(t, int64(0), result.Remaining)
+	})
+
+	t.Run("capacity and refill rate from the limit", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		apiKey := "api_key_3"
+		slow := configs.Limit{Requests: 20, Window: 40 * time.Second}
+
+		mock.ExpectEvalSha(tokenBucketScript.Hash(), []string{apiKey + ":20/40s"}, int64(20), float64(0.5)).
+			SetVal([]interface{}{int64(0), int64(1), int64(19), int64(0), int64(2000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{slow})
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("second bucket empty", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		apiKey := "api_key_4"
+
+		mock.ExpectEvalSha(tokenBucketScript.Hash(), []string{apiKey + ":5/s", apiKey + ":60/m"},
+			int64(5), float64(5), int64(60), float64(1)).
+			SetVal([]interface{}{int64(1), int64(2), int64(0), int64(1000), int64(60000)})
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond, perMinute})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, perMinute, result.Limit)
+	})
+
+	t.Run("zero limit", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		blocked := configs.Limit{Requests: 0, Window: time.Second}
+
+		result, err := repo.HasReachedLimit(ctx, "api_key_5", []configs.Limit{blocked})
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, blocked, result.Limit)
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		repo := &TokenBucketRepository{RateLimiterRepository: &RateLimiterRepository{RedisClient: db}}
+		apiKey := "api_key_6"
+
+		mock.ExpectEvalSha(tokenBucketScript.Hash(), []string{apiKey + ":5/s"}, int64(5), float64(5)).SetErr(redis.ErrClosed)
+
+		result, err := repo.HasReachedLimit(ctx, apiKey, []configs.Limit{perSecond})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
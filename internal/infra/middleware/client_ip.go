@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// getClientIP returns the address of the client that sent r. Forwarding
+// headers are only read when the immediate peer is a trusted proxy, and then
+// the right-most hop that is not a trusted proxy is the client.
+func getClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	peer = peer.Unmap().WithZone("")
+	if !isTrusted(peer, trustedProxies) {
+		return peer.String()
+	}
+
+	hops := forwardedHops(r)
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+		if !isTrusted(hop, trustedProxies) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedHops lists the addresses a request went through, from the client
+// to the last proxy, using Forwarded, X-Forwarded-For or X-Real-IP, in that
+// order of preference.
+func forwardedHops(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(name, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		hops = append(hops, realIP)
+	}
+	return hops
+}
+
+// parseHop reads an address as found in forwarding headers, which may be
+// quoted, bracketed or followed by a port, as in "[2001:db8::1]:4711".
+func parseHop(value string) (netip.Addr, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// aggregateIP returns the network of ip, such as "2001:db8:1:2::/64", so
+// that clients rotating addresses within a network share a single limit. A
+// prefix length of zero, or one covering the whole address, keeps ip as is.
+func aggregateIP(ip string, ipv4Bits, ipv6Bits int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	bits := ipv6Bits
+	if addr.Is4() {
+		bits = ipv4Bits
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return ip
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		expectedIP string
+	}{
+		{
+			name:       "port is stripped",
+			remoteAddr: "203.0.113.7:54321",
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "IPv6 peer with port",
+			remoteAddr: "[2001:db9::1]:443",
+			expectedIP: "2001:db9::1",
+		},
+		{
+			name:       "IPv4-mapped peer",
+			remoteAddr: "[::ffff:203.0.113.7]:443",
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "headers from untrusted peer are ignored",
+			remoteAddr: "203.0.113.7:54321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "right-most untrusted X-Forwarded-For hop",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.2"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For over several header lines",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1", "10.0.0.3"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			expectedIP: "10.0.0.3",
+		},
+		{
+			name:       "invalid hop stops the walk",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage, 10.0.0.2"}},
+			expectedIP: "10.0.0.2",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "Forwarded is preferred",
+			remoteAddr: "10.0.0.1:54321",
+			headers: map[string][]string{
+				"Forwarded":       {`for="[2001:db9::7]:4711";proto=https, for=10.0.0.2`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			expectedIP: "2001:db9::7",
+		},
+		{
+			name:       "Forwarded with IPv4 and port",
+			remoteAddr: "[2001:db8::1]:443",
+			headers:    map[string][]string{"Forwarded": {"for=198.51.100.1:47011;by=10.0.0.2"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "obfuscated Forwarded hop",
+			remoteAddr: "10.0.0.1:54321",
+			headers:    map[string][]string{"Forwarded": {"for=_hidden"}},
+			expectedIP: "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				req.Header[name] = values
+			}
+			assert.Equal(t, tt.expectedIP, getClientIP(req, trusted))
+		})
+	}
+}
+
+func TestAggregateIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		ip         string
+		ipv4Bits   int
+		ipv6Bits   int
+		expectedIP string
+	}{
+		{"IPv6 /64", "2001:db8:1:2:aaaa:bbbb:cccc:dddd", 32, 64, "2001:db8:1:2::/64"},
+		{"IPv6 /56", "2001:db8:1:2ff::1", 32, 56, "2001:db8:1:200::/56"},
+		{"IPv4 /24", "198.51.100.77", 24, 64, "198.51.100.0/24"},
+		{"full IPv4 address", "198.51.100.77", 32, 64, "198.51.100.77"},
+		{"full IPv6 address", "2001:db8::1", 32, 128, "2001:db8::1"},
+		{"disabled", "2001:db8::1", 0, 0, "2001:db8::1"},
+		{"not an IP", "unix-socket", 24, 64, "unix-socket"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedIP, aggregateIP(tt.ip, tt.ipv4Bits, tt.ipv6Bits))
+		})
+	}
+}
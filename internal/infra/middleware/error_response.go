@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+)
+
+// rejection describes why a request was rejected. Limit, Window and
+// RetryAfter, in seconds, are zero when they do not apply.
+type rejection struct {
+	Status     int    `json:"status"`
+	Title      string `json:"-"`
+	Detail     string `json:"-"`
+	Limit      int64  `json:"limit,omitempty"`
+	Window     int64  `json:"window,omitempty"`
+	RetryAfter int64  `json:"retry_after,omitempty"`
+}
+
+func newRejection(decision Decision) rejection {
+	return rejection{
+		Status:     decision.StatusCode,
+		Title:      http.StatusText(decision.StatusCode),
+		Detail:     decision.Message,
+		Limit:      decision.Limit.Requests,
+		Window:     seconds(decision.Limit.Window),
+		RetryAfter: seconds(decision.RetryAfter),
+	}
+}
+
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	rejection
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+	rejection
+}
+
+// writeRejection writes rej in the format asked for in the Accept header of
+// r, or else in ERROR_FORMAT, logging rendering errors to logger.
+func writeRejection(w http.ResponseWriter, r *http.Request, config *configs.Config, logger *slog.Logger, rej rejection) {
+	var contentType string
+	var body []byte
+	var err error
+	switch negotiateFormat(r.Header.Get("Accept"), config) {
+	case configs.JSONFormat:
+		contentType = "application/json"
+		body, err = json.Marshal(errorBody{Error: rej.Detail, rejection: rej})
+	case configs.ProblemFormat:
+		contentType = "application/problem+json"
+		body, err = json.Marshal(problemDetails{Type: "about:blank", Title: rej.Title, Detail: rej.Detail, rejection: rej})
+	case configs.TemplateFormat:
+		var buf bytes.Buffer
+		contentType = config.ErrorTemplate.ContentType
+		err = config.ErrorTemplate.Execute(&buf, rej)
+		body = buf.Bytes()
+	default:
+		http.Error(w, rej.Detail, rej.Status)
+		return
+	}
+	if err != nil {
+		logger.Error("error rendering rejection", "error", err)
+		http.Error(w, rej.Detail, rej.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(rej.Status)
+	w.Write(body)
+}
+
+// mediaOffer is a media type rejections can be rendered as.
+type mediaOffer struct {
+	mediaType string
+	format    string
+}
+
+// mediaRange is an entry of an Accept header.
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateFormat picks the format with the highest quality in accept among
+// those on offer, preferring exact media types over wildcards, which only
+// stand for ERROR_FORMAT. ERROR_FORMAT is used when accept is empty, accepts
+// anything or accepts nothing on offer, unless accept excludes it with q=0.
+func negotiateFormat(accept string, config *configs.Config) string {
+	preferred := config.ErrorFormat
+	if preferred == "" || (preferred == configs.TemplateFormat && config.ErrorTemplate == nil) {
+		preferred = configs.TextFormat
+	}
+	offers := []mediaOffer{
+		{"text/plain", configs.TextFormat},
+		{"application/json", configs.JSONFormat},
+		{"application/problem+json", configs.ProblemFormat},
+	}
+	if config.ErrorTemplate != nil {
+		if mediaType, _, err := mime.ParseMediaType(config.ErrorTemplate.ContentType); err == nil {
+			offers = append(offers, mediaOffer{mediaType, configs.TemplateFormat})
+		}
+	}
+	ranges := parseAccept(accept)
+
+	best, bestQuality, bestExact, bestPosition := -1, 0.0, false, 0
+	excluded := make([]bool, len(offers))
+	for i, offer := range offers {
+		quality, exact, position, ok := matchOffer(ranges, offer, offer.format == preferred)
+		if !ok {
+			continue
+		}
+		if quality <= 0 {
+			excluded[i] = true
+			continue
+		}
+		better := quality > bestQuality ||
+			quality == bestQuality && exact && !bestExact ||
+			quality == bestQuality && exact == bestExact && position < bestPosition ||
+			quality == bestQuality && exact == bestExact && position == bestPosition && offer.format == preferred
+		if best < 0 || better {
+			best, bestQuality, bestExact, bestPosition = i, quality, exact, position
+		}
+	}
+	if best >= 0 {
+		return offers[best].format
+	}
+
+	// Fall back to ERROR_FORMAT, or to the first format accept does not
+	// exclude when it excludes ERROR_FORMAT.
+	for i, offer := range offers {
+		if offer.format == preferred && excluded[i] {
+			for j, fallback := range offers {
+				if !excluded[j] {
+					return fallback.format
+				}
+			}
+		}
+	}
+	return preferred
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType, quality})
+	}
+	return ranges
+}
+
+// matchOffer finds the most specific range in ranges matching offer and
+// returns its quality and position. Wildcards only match the preferred offer.
+func matchOffer(ranges []mediaRange, offer mediaOffer, preferred bool) (quality float64, exact bool, position int, ok bool) {
+	family, _, _ := strings.Cut(offer.mediaType, "/")
+	specificity := -1
+	for i, r := range ranges {
+		current := -1
+		switch {
+		case r.mediaType == offer.mediaType:
+			current = 2
+		case preferred && r.mediaType == family+"/*":
+			current = 1
+		case preferred && r.mediaType == "*/*":
+			current = 0
+		}
+		if current > specificity {
+			specificity, quality, position = current, r.quality, i
+		}
+	}
+	return quality, specificity == 2, position, specificity >= 0
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	htmlTemplate := &configs.ErrorTemplate{ContentType: "text/html; charset=utf-8"}
+	jsonTemplate := &configs.ErrorTemplate{ContentType: "application/json"}
+
+	tests := []struct {
+		name     string
+		accept   string
+		config   *configs.Config
+		expected string
+	}{
+		{
+			name:     "Default format",
+			accept:   "",
+			config:   &configs.Config{},
+			expected: configs.TextFormat,
+		},
+		{
+			name:     "Configured format",
+			accept:   "",
+			config:   &configs.Config{ErrorFormat: configs.ProblemFormat},
+			expected: configs.ProblemFormat,
+		},
+		{
+			name:     "Any media type",
+			accept:   "*/*",
+			config:   &configs.Config{ErrorFormat: configs.JSONFormat},
+			expected: configs.JSONFormat,
+		},
+		{
+			name:     "Problem details asked for",
+			accept:   "application/problem+json",
+			config:   &configs.Config{},
+			expected: configs.ProblemFormat,
+		},
+		{
+			name:     "Highest quality wins",
+			accept:   "text/plain;q=0.5, application/json;q=0.9",
+			config:   &configs.Config{ErrorFormat: configs.ProblemFormat},
+			expected: configs.JSONFormat,
+		},
+		{
+			name:     "Exact media type wins over wildcard",
+			accept:   "*/*, application/json",
+			config:   &configs.Config{},
+			expected: configs.JSONFormat,
+		},
+		{
+			name:     "Nothing on offer",
+			accept:   "image/png",
+			config:   &configs.Config{ErrorFormat: configs.JSONFormat},
+			expected: configs.JSONFormat,
+		},
+		{
+			name:     "Template asked for",
+			accept:   "text/html",
+			config:   &configs.Config{ErrorTemplate: htmlTemplate},
+			expected: configs.TemplateFormat,
+		},
+		{
+			name:     "Template not loaded",
+			accept:   "text/html",
+			config:   &configs.Config{ErrorFormat: configs.TemplateFormat},
+			expected: configs.TextFormat,
+		},
+		{
+			name:     "JSON template keeps JSON on offer",
+			accept:   "application/json",
+			config:   &configs.Config{ErrorTemplate: jsonTemplate},
+			expected: configs.JSONFormat,
+		},
+		{
+			name:     "JSON template configured",
+			accept:   "application/json",
+			config:   &configs.Config{ErrorFormat: configs.TemplateFormat, ErrorTemplate: jsonTemplate},
+			expected: configs.TemplateFormat,
+		},
+		{
+			name:     "Configured format excluded",
+			accept:   "application/json;q=0",
+			config:   &configs.Config{ErrorFormat: configs.JSONFormat},
+			expected: configs.TextFormat,
+		},
+		{
+			name:     "Configured format excluded despite wildcard",
+			accept:   "*/*, application/json;q=0",
+			config:   &configs.Config{ErrorFormat: configs.JSONFormat},
+			expected: configs.TextFormat,
+		},
+		{
+			name:     "Other format excluded",
+			accept:   "text/plain;q=0",
+			config:   &configs.Config{ErrorFormat: configs.ProblemFormat},
+			expected: configs.ProblemFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := negotiateFormat(tt.accept, tt.config)
+			if format != tt.expected {
+				t.Errorf("Expected format: %v, got: %v", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestWriteRejection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rejection.html")
+	if err := os.WriteFile(path, []byte("<p>{{.Status}} {{.Detail}}, retry in {{.RetryAfter}}s</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	errorTemplate, err := configs.LoadErrorTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &quota{result: &database.LimitResult{
+		Allowed:    false,
+		Limit:      configs.Limit{Requests: 10, Window: time.Minute},
+		RetryAfter: 30 * time.Second,
+	}}
+	limited := newRejection(newDecision(rateLimitMsg, http.StatusTooManyRequests, q))
+
+	tests := []struct {
+		name         string
+		config       *configs.Config
+		rej          rejection
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "Text",
+			config:       &configs.Config{},
+			rej:          limited,
+			expectedType: "text/plain; charset=utf-8",
+			expectedBody: rateLimitMsg + "\n",
+		},
+		{
+			name:         "JSON",
+			config:       &configs.Config{ErrorFormat: configs.JSONFormat},
+			rej:          limited,
+			expectedType: "application/json",
+			expectedBody: `{"error":"` + rateLimitMsg + `","status":429,"limit":10,"window":60,"retry_after":30}`,
+		},
+		{
+			name:         "JSON without limit",
+			config:       &configs.Config{ErrorFormat: configs.JSONFormat},
+			rej:          newRejection(newDecision(invalidKey, http.StatusUnauthorized, nil)),
+			expectedType: "application/json",
+			expectedBody: `{"error":"Invalid API Key","status":401}`,
+		},
+		{
+			name:         "Problem details",
+			config:       &configs.Config{ErrorFormat: configs.ProblemFormat},
+			rej:          limited,
+			expectedType: "application/problem+json",
+			expectedBody: `{"type":"about:blank","title":"Too Many Requests","detail":"` + rateLimitMsg + `","status":429,"limit":10,"window":60,"retry_after":30}`,
+		},
+		{
+			name:         "Template",
+			config:       &configs.Config{ErrorFormat: configs.TemplateFormat, ErrorTemplate: errorTemplate},
+			rej:          limited,
+			expectedType: "text/html; charset=utf-8",
+			expectedBody: "<p>429 " + rateLimitMsg + ", retry in 30s</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			rec := httptest.NewRecorder()
+
+			writeRejection(rec, req, tt.config, discardLogger, tt.rej)
+			if rec.Code != tt.rej.Status {
+				t.Errorf("Expected code: %v, got: %v", tt.rej.Status, rec.Code)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("Expected content type: %v, got: %v", tt.expectedType, contentType)
+			}
+			if body := rec.Body.String(); body != tt.expectedBody {
+				t.Errorf("Expected body: %v, got: %v", tt.expectedBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+)
+
+// quota records the limits a request was counted against and the state the
+// store reported for them, so the handler can tell the client about it.
+type quota struct {
+	limits []configs.Limit
+	result *database.LimitResult
+}
+
+type quotaKey struct{}
+
+func withQuota(ctx context.Context, q *quota) context.Context {
+	return context.WithValue(ctx, quotaKey{}, q)
+}
+
+func quotaFrom(ctx context.Context) *quota {
+	q, _ := ctx.Value(quotaKey{}).(*quota)
+	return q
+}
+
+func (q *quota) setLimits(limits []configs.Limit) {
+	if q != nil {
+		q.limits, q.result = limits, nil
+	}
+}
+
+func (q *quota) setResult(result *database.LimitResult) {
+	if q != nil {
+		q.result = result
+	}
+}
+
+// blockFor makes a rejected client wait at least until a block of d is over.
+func (q *quota) blockFor(d time.Duration) {
+	if q != nil && q.result != nil {
+		q.result.RetryAfter = max(q.result.RetryAfter, d)
+	}
+}
+
+// retryAfter is how long a rejected client should wait before trying again,
+// or 0 when the store could not tell.
+func (q *quota) retryAfter() time.Duration {
+	if q == nil || q.result == nil || q.result.Allowed {
+		return 0
+	}
+	return q.result.RetryAfter
+}
+
+// setRateLimitHeaders writes the RateLimit headers of the IETF draft for q.
+// The limit reported is the one that tripped or, when none did, the one with
+// the fewest requests remaining.
+func setRateLimitHeaders(header http.Header, q *quota) {
+	if q == nil || len(q.limits) == 0 {
+		return
+	}
+	policies := make([]string, len(q.limits))
+	for i, limit := range q.limits {
+		policies[i] = fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Window))
+	}
+	header.Set("RateLimit-Policy", strings.Join(policies, ", "))
+
+	if q.result == nil || q.result.Limit.Window == 0 {
+		return
+	}
+	limit := q.result.Limit.Requests
+	remaining := max(0, q.result.Remaining)
+	if !q.result.Allowed {
+		remaining = 0
+	}
+	reset := seconds(q.result.ResetAfter)
+	header.Set("RateLimit-Limit", strconv.FormatInt(limit, 10))
+	header.Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	header.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	header.Set("RateLimit", fmt.Sprintf("limit=%d, remaining=%d, reset=%d", limit, remaining, reset))
+}
+
+// seconds rounds d up to whole seconds, as the headers count in seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// setRetryAfter tells a client rejected for exceeding its limits when its
+// block or the window of the tripped limit is over.
+func setRetryAfter(header http.Header, q *quota) {
+	if retryAfter := q.retryAfter(); retryAfter > 0 {
+		header.Set("Retry-After", strconv.FormatInt(seconds(retryAfter), 10))
+	}
+}
@@ -0,0 +1,273 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+)
+
+func TestSetRateLimitHeaders(t *testing.T) {
+	perSecond := configs.Limit{Requests: 10, Window: time.Second}
+	perHour := configs.Limit{Requests: 1000, Window: time.Hour}
+
+	tests := []struct {
+		name     string
+		quota    *quota
+		expected http.Header
+	}{
+		{
+			name:     "No quota",
+			quota:    nil,
+			expected: http.Header{},
+		},
+		{
+			name: "Allowed",
+			quota: &quota{
+				limits: []configs.Limit{perSecond, perHour},
+				result: &database.LimitResult{Allowed: true, Limit: perSecond, Remaining: 7, ResetAfter: 400 * time.Millisecond},
+			},
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1, 1000;w=3600"},
+				"Ratelimit-Limit":     {"10"},
+				"Ratelimit-Remaining": {"7"},
+				"Ratelimit-Reset":     {"1"},
+				"Ratelimit":           {"limit=10, remaining=7, reset=1"},
+			},
+		},
+		{
+			name: "Denied",
+			quota: &quota{
+				limits: []configs.Limit{perSecond, perHour},
+				result: &database.LimitResult{Allowed: false, Limit: perHour, Remaining: 3, ResetAfter: 90 * time.Second},
+			},
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1, 1000;w=3600"},
+				"Ratelimit-Limit":     {"1000"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"90"},
+				"Ratelimit":           {"limit=1000, remaining=0, reset=90"},
+			},
+		},
+		{
+			name: "Blacklisted",
+			quota: &quota{
+				limits: []configs.Limit{perSecond},
+				result: database.BlackListedResult(database.BlackListValue(perSecond), []configs.Limit{perSecond}, 2*time.Minute),
+			},
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1"},
+				"Ratelimit-Limit":     {"10"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"120"},
+				"Ratelimit":           {"limit=10, remaining=0, reset=120"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			setRateLimitHeaders(header, tt.quota)
+			if !reflect.DeepEqual(header, tt.expected) {
+				t.Errorf("Expected headers: %v, got: %v", tt.expected, header)
+			}
+		})
+	}
+}
+
+func TestEnforceRecordsQuota(t *testing.T) {
+	limits := []configs.Limit{{Requests: 10, Window: time.Second}}
+	result := &database.LimitResult{Allowed: true, Limit: limits[0], Remaining: 9, ResetAfter: time.Second}
+	md := &RateLimiterMiddleware{s: &MockStore{
+		GetFunc: func(ctx context.Context, key string) (string, error) {
+			return "", nil
+		},
+		HasReachedLimitFunc: func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+			return result, nil
+		},
+	}}
+
+	q := &quota{}
+	ctx := withQuota(context.Background(), q)
+	msg, _ := md.enforce(ctx, ctx, "blacklist@{key}", "requests@{key}", configs.KeyPolicy{Limits: limits})
+	if msg != "" {
+		t.Fatalf("Expected request to pass, got: %v", msg)
+	}
+	if !reflect.DeepEqual(q.limits, limits) {
+		t.Errorf("Expected limits: %v, got: %v", limits, q.limits)
+	}
+	if q.result != result {
+		t.Errorf("Expected result: %v, got: %v", result, q.result)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	limits := []configs.Limit{{Requests: 10, Window: time.Minute}}
+	tripped := func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+		return &database.LimitResult{Allowed: false, Limit: limits[0], RetryAfter: 20 * time.Second}, nil
+	}
+
+	tests := []struct {
+		name        string
+		store       RateLimiterStrategy
+		blockedTime int64
+		expected    string
+	}{
+		{
+			name: "Blacklisted",
+			store: &MockTTLStore{
+				MockStore: MockStore{GetFunc: func(ctx context.Context, key string) (string, error) {
+					return "Too many requests", nil
+				}},
+				TTLFunc: func(ctx context.Context, key string) (time.Duration, error) {
+					if key != "blacklist@{key}" {
+						t.Errorf("Expected key: blacklist@{key}, got: %v", key)
+					}
+					return 1500 * time.Millisecond, nil
+				},
+			},
+			expected: "2",
+		},
+		{
+			name: "Blacklisted without TTL",
+			store: &MockStore{GetFunc: func(ctx context.Context, key string) (string, error) {
+				return "Too many requests", nil
+			}},
+			expected: "",
+		},
+		{
+			name: "Limit tripped",
+			store: &MockStore{
+				GetFunc:             func(ctx context.Context, key string) (string, error) { return "", nil },
+				HasReachedLimitFunc: tripped,
+				SaveFunc:            func(ctx context.Context, key, value string, ttl int64) error { return nil },
+			},
+			expected: "20",
+		},
+		{
+			name: "Limit tripped and blacklisted",
+			store: &MockStore{
+				GetFunc:             func(ctx context.Context, key string) (string, error) { return "", nil },
+				HasReachedLimitFunc: tripped,
+				SaveFunc:            func(ctx context.Context, key, value string, ttl int64) error { return nil },
+			},
+			blockedTime: 300,
+			expected:    "300",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := &RateLimiterMiddleware{s: tt.store}
+			q := &quota{}
+			ctx := withQuota(context.Background(), q)
+			_, code := md.enforce(ctx, ctx, "blacklist@{key}", "requests@{key}", configs.KeyPolicy{Limits: limits, BlockedTime: tt.blockedTime})
+			if code != http.StatusTooManyRequests {
+				t.Fatalf("Expected code: %v, got: %v", http.StatusTooManyRequests, code)
+			}
+
+			header := http.Header{}
+			setRetryAfter(header, q)
+			if got := header.Get("Retry-After"); got != tt.expected {
+				t.Errorf("Expected Retry-After: %q, got: %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBlockedAndShapedHeaders(t *testing.T) {
+	perSecond := configs.Limit{Requests: 10, Window: time.Second}
+	perHour := configs.Limit{Requests: 1000, Window: time.Hour}
+	limits := []configs.Limit{perSecond, perHour}
+	blackListed := func(value string) MockStore {
+		return MockStore{GetFunc: func(ctx context.Context, key string) (string, error) {
+			return value, nil
+		}}
+	}
+	reserve := func(result *database.LimitResult) func(ctx context.Context, key string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error) {
+		return func(ctx context.Context, key string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error) {
+			return result, 0, nil
+		}
+	}
+
+	tests := []struct {
+		name         string
+		store        RateLimiterStrategy
+		expectedCode int
+		expected     http.Header
+	}{
+		{
+			name: "Blacklisted",
+			store: &MockTTLStore{
+				MockStore: blackListed(database.BlackListValue(perHour)),
+				TTLFunc: func(ctx context.Context, key string) (time.Duration, error) {
+					return 300 * time.Second, nil
+				},
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1, 1000;w=3600"},
+				"Ratelimit-Limit":     {"1000"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"300"},
+				"Ratelimit":           {"limit=1000, remaining=0, reset=300"},
+				"Retry-After":         {"300"},
+			},
+		},
+		{
+			name: "Shaped and rejected",
+			store: &MockShapingStore{
+				MockStore:   blackListed(""),
+				ReserveFunc: reserve(&database.LimitResult{Allowed: false, Limit: perSecond, RetryAfter: 2500 * time.Millisecond, ResetAfter: 2500 * time.Millisecond}),
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1, 1000;w=3600"},
+				"Ratelimit-Limit":     {"10"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"3"},
+				"Ratelimit":           {"limit=10, remaining=0, reset=3"},
+				"Retry-After":         {"3"},
+			},
+		},
+		{
+			name: "Shaped and allowed",
+			store: &MockShapingStore{
+				MockStore:   blackListed(""),
+				ReserveFunc: reserve(&database.LimitResult{Allowed: true, Limit: perHour, ResetAfter: 3600 * time.Millisecond}),
+			},
+			expectedCode: 0,
+			expected: http.Header{
+				"Ratelimit-Policy":    {"10;w=1, 1000;w=3600"},
+				"Ratelimit-Limit":     {"1000"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"4"},
+				"Ratelimit":           {"limit=1000, remaining=0, reset=4"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := &RateLimiterMiddleware{s: tt.store}
+			q := &quota{}
+			ctx := withQuota(context.Background(), q)
+			_, code := md.enforce(ctx, ctx, "blacklist@{key}", "requests@{key}", configs.KeyPolicy{Limits: limits})
+			if code != tt.expectedCode {
+				t.Fatalf("Expected code: %v, got: %v", tt.expectedCode, code)
+			}
+
+			header := http.Header{}
+			setRateLimitHeaders(header, q)
+			setRetryAfter(header, q)
+			if !reflect.DeepEqual(header, tt.expected) {
+				t.Errorf("Expected headers: %v, got: %v", tt.expected, header)
+			}
+		})
+	}
+}
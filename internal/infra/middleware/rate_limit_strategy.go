@@ -2,16 +2,152 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/carlosmeds/rate-limiter/configs"
 	"github.com/carlosmeds/rate-limiter/internal/infra/database"
 )
 
 type RateLimiterStrategy interface {
-	HasReachedLimit(ctx context.Context, apiKey string, limit int64) (bool, error)
+	HasReachedLimit(ctx context.Context, apiKey string, limits []configs.Limit) (*database.LimitResult, error)
 	Get(ctx context.Context, key string) (string, error)
 	Save(ctx context.Context, key, value string, ttl int64) error
 }
 
+// AtomicStrategy is implemented by strategies that can check the blacklist,
+// count the request and blacklist the client in a single round trip.
+type AtomicStrategy interface {
+	RateLimiterStrategy
+	CheckAndBlock(ctx context.Context, blackListKey, apiKey string, limits []configs.Limit, blockedTime int64) (*database.LimitResult, error)
+}
+
+// CircuitBreakerStrategy is implemented by strategies whose store sits behind
+// a circuit breaker, so requests can skip the store while it is open.
+type CircuitBreakerStrategy interface {
+	CircuitOpen() bool
+}
+
+// TTLStrategy is implemented by strategies that can tell how long a key has
+// left to live, so blacklisted clients learn when to retry.
+type TTLStrategy interface {
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// ExactRetryStrategy is implemented by strategies whose denials already tell
+// the client exactly when its next request will be allowed, so they are never
+// blacklisted, which would only make the client wait longer.
+type ExactRetryStrategy interface {
+	ExactRetryAfter() bool
+}
+
+// ShapingStrategy is implemented by strategies that can delay a request until
+// it fits the rate instead of rejecting it. Reserve returns how long an
+// allowed request must wait, and Release gives back a reservation whose
+// request gave up waiting.
+type ShapingStrategy interface {
+	RateLimiterStrategy
+	Reserve(ctx context.Context, apiKey string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error)
+	Release(ctx context.Context, apiKey string, limits []configs.Limit) error
+}
+
 func NewRateLimiterStrategy() RateLimiterStrategy {
-	return database.NewRateLimiterRepository()
+	config := configs.GetConfig()
+	switch config.StorageBackend {
+	case configs.MemoryBackend:
+		if config.Algorithm != "" && config.Algorithm != configs.FixedWindow {
+			fmt.Println("Memory storage only supports", configs.FixedWindow, "ignoring", config.Algorithm)
+		}
+		cleanupInterval := config.MemoryCleanupInterval
+		if cleanupInterval <= 0 {
+			cleanupInterval = time.Minute
+		}
+		return database.NewMemoryStore(cleanupInterval)
+	case configs.HybridBackend:
+		if config.Algorithm != "" && config.Algorithm != configs.FixedWindow {
+			fmt.Println("Hybrid storage only supports", configs.FixedWindow, "ignoring", config.Algorithm)
+		}
+		syncInterval := config.HybridSyncInterval
+		if syncInterval <= 0 {
+			syncInterval = 100 * time.Millisecond
+		}
+		return database.NewHybridStore(syncInterval, config.HybridOvershoot)
+	}
+	return newRedisStrategy(database.NewRateLimiterRepository(), config.Algorithm, config)
+}
+
+// NewAlgorithmStrategies returns the function creating the strategies of the
+// tiers whose algorithm differs from ALGORITHM. They share the Redis client
+// and circuit breaker of strategy, which must come from
+// NewRateLimiterStrategy. Memory and hybrid storage return nil, since they
+// only count with fixed_window.
+func NewAlgorithmStrategies(strategy RateLimiterStrategy) func(algorithm string) RateLimiterStrategy {
+	config := configs.GetConfig()
+	if config.StorageBackend == configs.MemoryBackend || config.StorageBackend == configs.HybridBackend {
+		return nil
+	}
+	redisStrategy, ok := strategy.(interface {
+		Repository() *database.RateLimiterRepository
+	})
+	if !ok {
+		return nil
+	}
+	return func(algorithm string) RateLimiterStrategy {
+		return newRedisStrategy(redisStrategy.Repository(), algorithm, configs.GetConfig())
+	}
+}
+
+func newRedisStrategy(repo *database.RateLimiterRepository, algorithm string, config *configs.Config) RateLimiterStrategy {
+	switch algorithm {
+	case configs.TokenBucket:
+		return &database.TokenBucketRepository{RateLimiterRepository: repo}
+	case configs.SlidingWindowLog:
+		return &database.SlidingWindowLogRepository{RateLimiterRepository: repo}
+	case configs.SlidingWindowCounter:
+		return &database.SlidingWindowCounterRepository{RateLimiterRepository: repo}
+	case configs.GCRA:
+		return &database.GCRARepository{RateLimiterRepository: repo}
+	case configs.LeakyBucket:
+		return &database.LeakyBucketRepository{
+			RateLimiterRepository: repo,
+			MaxQueue:              config.LeakyBucketMaxQueue,
+			MaxWait:               config.LeakyBucketMaxWait,
+		}
+	default:
+		return &database.FixedWindowRepository{RateLimiterRepository: repo}
+	}
+}
+
+// algorithmStrategies keeps a middleware enforcing limits with the strategy
+// of every algorithm used by a tier other than the configured ALGORITHM.
+// Memory and hybrid storage only count with fixed_window, so tiers always
+// share the main strategy there.
+type algorithmStrategies struct {
+	mu          sync.Mutex
+	newStrategy func(algorithm string) RateLimiterStrategy
+	middlewares map[string]*RateLimiterMiddleware
+}
+
+func (a *algorithmStrategies) get(algorithm string, config *configs.Config, logger *slog.Logger) (*RateLimiterMiddleware, bool) {
+	current := config.Algorithm
+	if current == "" {
+		current = configs.FixedWindow
+	}
+	if a.newStrategy == nil || algorithm == "" || algorithm == current ||
+		config.StorageBackend == configs.MemoryBackend || config.StorageBackend == configs.HybridBackend {
+		return nil, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	md, ok := a.middlewares[algorithm]
+	if !ok {
+		if a.middlewares == nil {
+			a.middlewares = make(map[string]*RateLimiterMiddleware)
+		}
+		md = NewRateLimiterMiddleware(a.newStrategy(algorithm), WithLogger(logger))
+		a.middlewares[algorithm] = md
+	}
+	return md, true
 }
@@ -2,38 +2,154 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"net/netip"
+	"time"
 
 	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
 )
 
 const (
 	rateLimitMsg   = "you have reached the maximum number of requests or actions allowed within a certain time frame"
 	invalidKey     = "Invalid API Key"
 	internalErrMsg = "Internal Server Error"
+	unavailableMsg = "Service Unavailable"
+	forbiddenMsg   = "Forbidden"
 )
 
 func (md *RateLimiterMiddleware) CheckRateLimit(r *http.Request) (errMsg string, statusCode int) {
-	ctx := r.Context()
-	apiKey, clientIP := getCredentials(r)
+	config := md.getConfig()
+	apiKey, clientIP := getCredentials(r, config.TrustedProxies)
+	if errMsg, statusCode, listed := checkAccessLists(clientIP, config); listed {
+		if statusCode == http.StatusForbidden {
+			md.log().Debug("client IP in denylist", "ip", clientIP)
+		}
+		return errMsg, statusCode
+	}
+	route := md.matchRoute(r, config)
+	errMsg, statusCode = md.checkRateLimit(r, config, route, apiKey, clientIP)
+	if statusCode == http.StatusInternalServerError {
+		return md.applyFailurePolicy(r, config, route, apiKey, clientIP, errMsg, statusCode)
+	}
+	return errMsg, statusCode
+}
 
-	blackListKey := getBlackListKey(apiKey, clientIP)
-	errMsg, statusCode = md.isBlackListed(ctx, blackListKey)
+// checkAccessLists lets clients in the allowlist through without counting
+// their requests and rejects clients in the denylist, before the store is
+// used at all. The denylist wins when a client is in both.
+func checkAccessLists(client string, config *configs.Config) (errMsg string, statusCode int, listed bool) {
+	clientIP, err := netip.ParseAddr(client)
+	if err != nil {
+		return "", 0, false
+	}
+	if config.Denylist.Contains(clientIP) {
+		return forbiddenMsg, http.StatusForbidden, true
+	}
+	if config.Allowlist.Contains(clientIP) {
+		return "", 0, true
+	}
+	return "", 0, false
+}
+
+// applyFailurePolicy decides what happens to a request the store could not
+// rate limit, following the policy of its route when it has one.
+func (md *RateLimiterMiddleware) applyFailurePolicy(r *http.Request, config *configs.Config, route *configs.RoutePolicy, apiKey, clientIP, errMsg string, statusCode int) (string, int) {
+	failurePolicy := config.FailurePolicy
+	if route != nil && route.FailurePolicy != "" {
+		failurePolicy = route.FailurePolicy
+	}
+	switch failurePolicy {
+	case configs.FailOpen:
+		md.log().Warn("rate limiter store unavailable, letting request through")
+		return "", 0
+	case configs.FailClosed:
+		md.log().Warn("rate limiter store unavailable, rejecting request")
+		return unavailableMsg, http.StatusServiceUnavailable
+	case configs.FailToMemory:
+		md.log().Warn("rate limiter store unavailable, falling back to memory")
+		return md.fallbackMiddleware().checkRateLimit(r, config, route, apiKey, clientIP)
+	}
+	return errMsg, statusCode
+}
+
+func (md *RateLimiterMiddleware) fallbackMiddleware() *RateLimiterMiddleware {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	if md.fallback == nil {
+		md.fallbackStore = database.NewMemoryStore(time.Minute)
+		md.fallback = NewRateLimiterMiddleware(md.fallbackStore, WithLogger(md.logger))
+	}
+	return md.fallback
+}
+
+// Close stops the in-memory store the memory failure policy falls back to.
+func (md *RateLimiterMiddleware) Close() {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	if md.fallbackStore != nil {
+		md.fallbackStore.Close()
+	}
+}
+
+// checkRateLimit counts the request of the client with the credentials found
+// by getCredentials, against the limits of route when it is not nil.
+func (md *RateLimiterMiddleware) checkRateLimit(r *http.Request, config *configs.Config, route *configs.RoutePolicy, apiKey, clientIP string) (errMsg string, statusCode int) {
+	ctx, cancel := withDecisionTimeout(r.Context(), config)
+	defer cancel()
+	clientIP = aggregateIP(clientIP, config.IPv4PrefixLength, config.IPv6PrefixLength)
+
+	policy, errMsg, statusCode := md.getPolicy(apiKey, config)
 	if errMsg != "" {
 		return errMsg, statusCode
 	}
 
-	config := configs.GetConfig()
-	limit, errMsg, statusCode := md.getLimit(apiKey, config)
+	blackListKey := getBlackListKey(apiKey, clientIP)
+	requestsKey := getRequestsKey(apiKey, clientIP)
+	if route != nil {
+		policy.Limits = route.Limits
+		blackListKey += ":" + route.String()
+		requestsKey += ":" + route.String()
+	}
+
+	target := md
+	if algorithmMiddleware, ok := md.algorithms.get(policy.Algorithm, config, md.logger); ok {
+		target = algorithmMiddleware
+		requestsKey += ":" + policy.Algorithm
+	}
+	if breaker, ok := target.s.(CircuitBreakerStrategy); ok && breaker.CircuitOpen() {
+		return internalErrMsg, http.StatusInternalServerError
+	}
+	return target.enforce(r.Context(), ctx, blackListKey, requestsKey, policy)
+}
+
+// enforce counts a request against the limits of policy. reqCtx is the
+// request's context and ctx the one bounding store calls.
+func (md *RateLimiterMiddleware) enforce(reqCtx, ctx context.Context, blackListKey, requestsKey string, policy configs.KeyPolicy) (errMsg string, statusCode int) {
+	limits := policy.Limits
+	quotaFrom(ctx).setLimits(limits)
+	if atomic, ok := md.s.(AtomicStrategy); ok {
+		return md.checkAndBlock(ctx, atomic, blackListKey, requestsKey, limits, policy.BlockedTime)
+	}
+
+	errMsg, statusCode = md.isBlackListed(ctx, blackListKey, limits)
 	if errMsg != "" {
 		return errMsg, statusCode
 	}
 
-	requestsKey := getRequestsKey(apiKey, clientIP)
-	errMsg, statusCode = md.getReachedLimit(ctx, requestsKey, limit)
+	if shaper, ok := md.s.(ShapingStrategy); ok {
+		return md.shape(reqCtx, ctx, shaper, requestsKey, limits)
+	}
+
+	result, errMsg, statusCode := md.getReachedLimit(ctx, requestsKey, limits)
 	if errMsg == rateLimitMsg {
-		md.AddToBlackList(ctx, blackListKey, config)
+		if exact, ok := md.s.(ExactRetryStrategy); ok && exact.ExactRetryAfter() {
+			return errMsg, statusCode
+		}
+		if policy.BlockedTime > 0 {
+			md.addToBlackList(ctx, blackListKey, database.BlackListValue(result.Limit), policy.BlockedTime)
+			quotaFrom(ctx).blockFor(time.Duration(policy.BlockedTime) * time.Second)
+		}
 		return errMsg, statusCode
 	}
 	if errMsg != "" {
@@ -43,64 +159,138 @@ func (md *RateLimiterMiddleware) CheckRateLimit(r *http.Request) (errMsg string,
 	return "", 0
 }
 
-func getCredentials(r *http.Request) (string, string) {
-	return r.Header.Get("API_KEY"), r.RemoteAddr
+// withDecisionTimeout bounds every store call made for one request, so a slow
+// store cannot stall request handling.
+func withDecisionTimeout(ctx context.Context, config *configs.Config) (context.Context, context.CancelFunc) {
+	if config.DecisionTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, config.DecisionTimeout)
+}
+
+func getCredentials(r *http.Request, trustedProxies []netip.Prefix) (string, string) {
+	return r.Header.Get("API_KEY"), getClientIP(r, trustedProxies)
 }
 
+// unknownClient identifies clients whose address could not be read, since
+// Redis Cluster ignores an empty hash tag.
+const unknownClient = "unknown"
+
+// Keys are hash tagged with the client identity, so every key of one client
+// lives on the same Redis Cluster slot and can be used by a single script.
 func getRequestsKey(key, clientIP string) string {
-	if key == "" {
-		return "requests@" + clientIP
-	}
-	return "requests@" + key
+	return "requests@{" + clientIdentity(key, clientIP) + "}"
 }
 
 func getBlackListKey(key, clientIP string) string {
-	if key == "" {
-		return "blacklist@" + clientIP
+	return "blacklist@{" + clientIdentity(key, clientIP) + "}"
+}
+
+func clientIdentity(key, clientIP string) string {
+	if key != "" {
+		return key
+	}
+	if clientIP == "" {
+		return unknownClient
 	}
-	return "blacklist@" + key
+	return clientIP
 }
 
-func (md *RateLimiterMiddleware) isBlackListed(ctx context.Context, key string) (string, int) {
+// isBlackListed rejects clients blocked after reaching one of limits, which
+// stays exhausted for as long as the block lasts.
+func (md *RateLimiterMiddleware) isBlackListed(ctx context.Context, key string, limits []configs.Limit) (string, int) {
 	blackListed, err := md.s.Get(ctx, key)
 	if err != nil {
 		return internalErrMsg, http.StatusInternalServerError
 	}
 	if blackListed != "" {
+		var blockedFor time.Duration
+		if ttl, ok := md.s.(TTLStrategy); ok {
+			blockedFor, _ = ttl.TTL(ctx, key)
+		}
+		quotaFrom(ctx).setResult(database.BlackListedResult(blackListed, limits, blockedFor))
 		return rateLimitMsg, http.StatusTooManyRequests
 	}
 
 	return "", 0
 }
 
-func (md *RateLimiterMiddleware) getLimit(apiKey string, config *configs.Config) (int64, string, int) {
-	if apiKey == "" {
-		return config.DefaultLimit, "", 0
-	}
-
-	limit, exists := config.ApiKeyLimits[apiKey]
+func (md *RateLimiterMiddleware) getPolicy(apiKey string, config *configs.Config) (configs.KeyPolicy, string, int) {
+	policy, exists := config.KeyPolicy(apiKey)
 	if !exists {
-		return 0, invalidKey, http.StatusUnauthorized
+		return configs.KeyPolicy{}, invalidKey, http.StatusUnauthorized
 	}
 
-	return limit, "", 0
+	return policy, "", 0
+}
+
+func (md *RateLimiterMiddleware) getReachedLimit(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, string, int) {
+	result, err := md.s.HasReachedLimit(ctx, key, limits)
+	if err != nil {
+		return nil, internalErrMsg, http.StatusInternalServerError
+	}
+	quotaFrom(ctx).setResult(result)
+	if !result.Allowed {
+		md.log().Debug("limit reached", "limit", result.Limit, "key", key)
+		return result, rateLimitMsg, http.StatusTooManyRequests
+	}
+	return result, "", 0
 }
 
-func (md *RateLimiterMiddleware) getReachedLimit(ctx context.Context, key string, limit int64) (string, int) {
-	reachedLimit, err := md.s.HasReachedLimit(ctx, key, limit)
+func (md *RateLimiterMiddleware) checkAndBlock(ctx context.Context, atomic AtomicStrategy, blackListKey, key string, limits []configs.Limit, blockedTime int64) (string, int) {
+	result, err := atomic.CheckAndBlock(ctx, blackListKey, key, limits, blockedTime)
 	if err != nil {
 		return internalErrMsg, http.StatusInternalServerError
 	}
-	if reachedLimit {
+	quotaFrom(ctx).setResult(result)
+	if !result.Allowed {
+		if !result.BlackListed {
+			md.log().Debug("limit reached", "limit", result.Limit, "key", key)
+		}
 		return rateLimitMsg, http.StatusTooManyRequests
 	}
 	return "", 0
 }
 
+// shape reserves a slot using storeCtx, bounded by the decision timeout, and
+// then waits for it as long as the request itself is alive. A request giving
+// up is told to retry once its slot would have come.
+func (md *RateLimiterMiddleware) shape(ctx, storeCtx context.Context, shaper ShapingStrategy, key string, limits []configs.Limit) (string, int) {
+	deadline, _ := ctx.Deadline()
+	result, wait, err := shaper.Reserve(storeCtx, key, limits, deadline)
+	if err != nil {
+		return internalErrMsg, http.StatusInternalServerError
+	}
+	quotaFrom(storeCtx).setResult(result)
+	if !result.Allowed {
+		return rateLimitMsg, http.StatusTooManyRequests
+	}
+	if wait <= 0 {
+		return "", 0
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return "", 0
+	case <-ctx.Done():
+		if err := shaper.Release(context.WithoutCancel(storeCtx), key, limits); err != nil {
+			md.log().Error("error releasing reservation", "key", key, "error", err)
+		}
+		result.Allowed, result.RetryAfter = false, wait
+		return rateLimitMsg, http.StatusTooManyRequests
+	}
+}
+
 func (md *RateLimiterMiddleware) AddToBlackList(ctx context.Context, key string,  config *configs.Config) error {
-	err := md.s.Save(ctx, key, "Too many requests", config.BlockedTime)
+	return md.addToBlackList(ctx, key, "Too many requests", config.BlockedTime)
+}
+
+func (md *RateLimiterMiddleware) addToBlackList(ctx context.Context, key, value string, blockedTime int64) error {
+	err := md.s.Save(ctx, key, value, blockedTime)
 	if err != nil {
-		fmt.Println("Error adding to blacklist", err)
+		md.log().Error("error adding to blacklist", "key", key, "error", err)
 		return err
 	}
 
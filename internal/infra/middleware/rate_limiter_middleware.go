@@ -1,22 +1,101 @@
 package middleware
 
 import (
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
 )
 
 type RateLimiterMiddleware struct {
-	s RateLimiterStrategy
+	s             RateLimiterStrategy
+	mu            sync.Mutex
+	fallback      *RateLimiterMiddleware
+	fallbackStore *database.MemoryStore
+	routes        routeMatcherCache
+	algorithms    algorithmStrategies
+	onLimited     RejectionHandler
+	onInvalidKey  RejectionHandler
+	onError       RejectionHandler
+	onDenied      RejectionHandler
+	logger        *slog.Logger
+	config        func() *configs.Config
+}
+
+type Option func(*RateLimiterMiddleware)
+
+// WithConfig makes the middleware read its configuration from config instead
+// of the one loaded from the env file.
+func WithConfig(config func() *configs.Config) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.config = config
+	}
+}
+
+// WithLogger logs rejected requests and store failures to logger. Nothing is
+// logged without it.
+func WithLogger(logger *slog.Logger) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.logger = logger
+	}
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func (md *RateLimiterMiddleware) log() *slog.Logger {
+	if md.logger != nil {
+		return md.logger
+	}
+	return discardLogger
+}
+
+func NewRateLimiterMiddleware(strategy RateLimiterStrategy, options ...Option) *RateLimiterMiddleware {
+	md := &RateLimiterMiddleware{s: strategy}
+	for _, option := range options {
+		option(md)
+	}
+	return md
+}
+
+// WithAlgorithms lets tiers count requests with an algorithm other than the
+// one of the middleware's strategy. The strategy of each algorithm is created
+// by newStrategy the first time a tier uses it.
+func (md *RateLimiterMiddleware) WithAlgorithms(newStrategy func(algorithm string) RateLimiterStrategy) *RateLimiterMiddleware {
+	md.algorithms.newStrategy = newStrategy
+	return md
 }
 
-func NewRateLimiterMiddleware(strategy RateLimiterStrategy) *RateLimiterMiddleware {
-	return &RateLimiterMiddleware{s: strategy}
+func (md *RateLimiterMiddleware) getConfig() *configs.Config {
+	if md.config != nil {
+		return md.config()
+	}
+	return configs.GetConfig()
 }
 
 func (md *RateLimiterMiddleware) RateLimiter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		errMsg, statusCode := md.CheckRateLimit(r)
+		quota := &quota{}
+		errMsg, statusCode := md.CheckRateLimit(r.WithContext(withQuota(r.Context(), quota)))
+		setRateLimitHeaders(w.Header(), quota)
 		if errMsg != "" {
-			http.Error(w, errMsg, statusCode)
+			decision := newDecision(errMsg, statusCode, quota)
+			if statusCode == http.StatusTooManyRequests {
+				setRetryAfter(w.Header(), quota)
+			}
+			if statusCode == http.StatusServiceUnavailable {
+				retryAfter := md.getConfig().FailureRetryAfter
+				if retryAfter <= 0 {
+					retryAfter = 1
+				}
+				w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+				decision.RetryAfter = time.Duration(retryAfter) * time.Second
+			}
+			md.rejectionHandler(statusCode)(w, r, decision)
 			return
 		}
 
@@ -1,12 +1,19 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
 )
 
 func TestGetCredentials(t *testing.T) {
@@ -31,6 +38,13 @@ func TestGetCredentials(t *testing.T) {
 			expectedKey:  "",
 			expectedAddr: "192.168.1.1",
 		},
+		{
+			name:         "Remote Address with port",
+			apiKey:       "test-api-key",
+			remoteAddr:   "192.168.1.1:54321",
+			expectedKey:  "test-api-key",
+			expectedAddr: "192.168.1.1",
+		},
 		{
 			name:         "Empty Remote Address",
 			apiKey:       "test-api-key",
@@ -56,7 +70,7 @@ func TestGetCredentials(t *testing.T) {
 			req.Header.Set("API_KEY", tt.apiKey)
 			req.RemoteAddr = tt.remoteAddr
 
-			apiKey, clientIP := getCredentials(req)
+			apiKey, clientIP := getCredentials(req, nil)
 			if apiKey != tt.expectedKey {
 				t.Errorf("Expected API Key: %v, got: %v", tt.expectedKey, apiKey)
 			}
@@ -78,25 +92,25 @@ func TestGetRequestsKey(t *testing.T) {
 			name:        "Valid API Key and Client IP",
 			apiKey:      "test-api-key",
 			clientIP:    "192.168.1.1",
-			expectedKey: "requests@test-api-key",
+			expectedKey: "requests@{test-api-key}",
 		},
 		{
 			name:        "Empty API Key",
 			apiKey:      "",
 			clientIP:    "192.168.1.1",
-			expectedKey: "requests@192.168.1.1",
+			expectedKey: "requests@{192.168.1.1}",
 		},
 		{
 			name:        "Empty Client IP",
 			apiKey:      "test-api-key",
 			clientIP:    "",
-			expectedKey: "requests@test-api-key",
+			expectedKey: "requests@{test-api-key}",
 		},
 		{
 			name:        "Empty API Key and Client IP",
 			apiKey:      "",
 			clientIP:    "",
-			expectedKey: "requests@",
+			expectedKey: "requests@{unknown}",
 		},
 	}
 
@@ -120,25 +134,25 @@ func TestGetBlackListKey(t *testing.T) {
 			name:        "Valid API Key and Client IP",
 			apiKey:      "test-api-key",
 			clientIP:    "192.168.1.1",
-			expectedKey: "blacklist@test-api-key",
+			expectedKey: "blacklist@{test-api-key}",
 		},
 		{
 			name:        "Empty API Key",
 			apiKey:      "",
 			clientIP:    "192.168.1.1",
-			expectedKey: "blacklist@192.168.1.1",
+			expectedKey: "blacklist@{192.168.1.1}",
 		},
 		{
 			name:        "Empty Client IP",
 			apiKey:      "test-api-key",
 			clientIP:    "",
-			expectedKey: "blacklist@test-api-key",
+			expectedKey: "blacklist@{test-api-key}",
 		},
 		{
 			name:        "Empty API Key and Client IP",
 			apiKey:      "",
 			clientIP:    "",
-			expectedKey: "blacklist@",
+			expectedKey: "blacklist@{unknown}",
 		},
 	}
 
@@ -200,7 +214,7 @@ func TestIsBlackListed(t *testing.T) {
 			}
 			md := &RateLimiterMiddleware{s: mockStore}
 
-			msg, code := md.isBlackListed(ctx, tt.key)
+			msg, code := md.isBlackListed(ctx, tt.key, []configs.Limit{{Requests: 5, Window: time.Second}})
 			if msg != tt.expectedMsg {
 				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
 			}
@@ -211,58 +225,79 @@ func TestIsBlackListed(t *testing.T) {
 	}
 }
 
-func TestGetLimit(t *testing.T) {
+func TestGetPolicy(t *testing.T) {
+	proBlockedTime := int64(60)
+	tiers := map[string]configs.Tier{
+		"pro": {Limits: []configs.Limit{{Requests: 100, Window: time.Minute}}, BlockedTime: &proBlockedTime, Algorithm: configs.GCRA},
+	}
+
 	tests := []struct {
-		name          string
-		apiKey        string
-		defaultLimit  int64
-		apiKeyLimits  map[string]int64
-		expectedLimit int64
-		expectedMsg   string
-		expectedCode  int
+		name           string
+		apiKey         string
+		defaultLimits  []configs.Limit
+		apiKeyLimits   map[string][]configs.Limit
+		apiKeyTiers    map[string]string
+		expectedPolicy configs.KeyPolicy
+		expectedMsg    string
+		expectedCode   int
 	}{
 		{
-			name:          "Empty API Key",
-			apiKey:        "",
-			defaultLimit:  100,
-			apiKeyLimits:  map[string]int64{},
-			expectedLimit: 100,
-			expectedMsg:   "",
-			expectedCode:  0,
+			name:           "Empty API Key",
+			apiKey:         "",
+			defaultLimits:  []configs.Limit{{Requests: 100, Window: time.Second}},
+			apiKeyLimits:   map[string][]configs.Limit{},
+			expectedPolicy: configs.KeyPolicy{Limits: []configs.Limit{{Requests: 100, Window: time.Second}}, BlockedTime: 300},
+			expectedMsg:    "",
+			expectedCode:   0,
 		},
 		{
 			name:          "Valid API Key",
 			apiKey:        "test-api-key",
-			defaultLimit:  100,
-			apiKeyLimits:  map[string]int64{"test-api-key": 200},
-			expectedLimit: 200,
-			expectedMsg:   "",
-			expectedCode:  0,
+			defaultLimits: []configs.Limit{{Requests: 100, Window: time.Second}},
+			apiKeyLimits: map[string][]configs.Limit{
+				"test-api-key": {{Requests: 10, Window: time.Second}, {Requests: 1000, Window: time.Hour}},
+			},
+			expectedPolicy: configs.KeyPolicy{Limits: []configs.Limit{{Requests: 10, Window: time.Second}, {Requests: 1000, Window: time.Hour}}, BlockedTime: 300},
+			expectedMsg:    "",
+			expectedCode:   0,
 		},
 		{
-			name:          "Invalid API Key",
-			apiKey:        "invalid-api-key",
-			defaultLimit:  100,
-			apiKeyLimits:  map[string]int64{"test-api-key": 200},
-			expectedLimit: 0,
-			expectedMsg:   invalidKey,
-			expectedCode:  http.StatusUnauthorized,
+			name:           "API Key On Tier",
+			apiKey:         "pro-api-key",
+			defaultLimits:  []configs.Limit{{Requests: 100, Window: time.Second}},
+			apiKeyLimits:   map[string][]configs.Limit{},
+			apiKeyTiers:    map[string]string{"pro-api-key": "pro"},
+			expectedPolicy: configs.KeyPolicy{Limits: []configs.Limit{{Requests: 100, Window: time.Minute}}, BlockedTime: 60, Algorithm: configs.GCRA},
+			expectedMsg:    "",
+			expectedCode:   0,
+		},
+		{
+			name:           "Invalid API Key",
+			apiKey:         "invalid-api-key",
+			defaultLimits:  []configs.Limit{{Requests: 100, Window: time.Second}},
+			apiKeyLimits:   map[string][]configs.Limit{"test-api-key": {{Requests: 200, Window: time.Minute}}},
+			expectedPolicy: configs.KeyPolicy{},
+			expectedMsg:    invalidKey,
+			expectedCode:   http.StatusUnauthorized,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockConfig := &configs.Config{
-				DefaultLimit: tt.defaultLimit,
-				ApiKeyLimits: tt.apiKeyLimits,
+				DefaultLimits: tt.defaultLimits,
+				ApiKeyLimits:  tt.apiKeyLimits,
+				ApiKeyTiers:   tt.apiKeyTiers,
+				Tiers:         tiers,
+				BlockedTime:   300,
 			}
 
 			mockStore := &MockStore{}
 			md := &RateLimiterMiddleware{s: mockStore}
 
-			limit, msg, code := md.getLimit(tt.apiKey, mockConfig)
-			if limit != tt.expectedLimit {
-				t.Errorf("Expected limit: %v, got: %v", tt.expectedLimit, limit)
+			policy, msg, code := md.getPolicy(tt.apiKey, mockConfig)
+			if !reflect.DeepEqual(policy, tt.expectedPolicy) {
+				t.Errorf("Expected policy: %v, got: %v", tt.expectedPolicy, policy)
 			}
 			if msg != tt.expectedMsg {
 				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
@@ -278,7 +313,7 @@ func TestGetReachedLimit(t *testing.T) {
 	tests := []struct {
 		name          string
 		key           string
-		limit         int64
+		limits        []configs.Limit
 		reachedLimit  bool
 		hasReachedErr error
 		expectedMsg   string
@@ -287,7 +322,7 @@ func TestGetReachedLimit(t *testing.T) {
 		{
 			name:          "Limit Not Reached",
 			key:           "requests@test-api-key",
-			limit:         100,
+			limits:        []configs.Limit{{Requests: 100, Window: time.Second}},
 			reachedLimit:  false,
 			hasReachedErr: nil,
 			expectedMsg:   "",
@@ -296,7 +331,7 @@ func TestGetReachedLimit(t *testing.T) {
 		{
 			name:          "Limit Reached",
 			key:           "requests@test-api-key",
-			limit:         100,
+			limits:        []configs.Limit{{Requests: 100, Window: time.Second}},
 			reachedLimit:  true,
 			hasReachedErr: nil,
 			expectedMsg:   rateLimitMsg,
@@ -305,7 +340,7 @@ func TestGetReachedLimit(t *testing.T) {
 		{
 			name:          "Error in HasReachedLimit",
 			key:           "requests@test-api-key",
-			limit:         100,
+			limits:        []configs.Limit{{Requests: 100, Window: time.Second}},
 			reachedLimit:  false,
 			hasReachedErr: fmt.Errorf("some error"),
 			expectedMsg:   internalErrMsg,
@@ -317,29 +352,376 @@ func TestGetReachedLimit(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 			mockStore := &MockStore{
-				HasReachedLimitFunc: func(ctx context.Context, key string, limit int64) (bool, error) {
+				HasReachedLimitFunc: func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
 					if key != tt.key {
 						t.Errorf("Expected key: %v, got: %v", tt.key, key)
 					}
-					if limit != tt.limit {
-						t.Errorf("Expected limit: %v, got: %v", tt.limit, limit)
+					if !reflect.DeepEqual(limits, tt.limits) {
+						t.Errorf("Expected limits: %v, got: %v", tt.limits, limits)
+					}
+					if tt.hasReachedErr != nil {
+						return nil, tt.hasReachedErr
+					}
+					return &database.LimitResult{Allowed: !tt.reachedLimit, Limit: limits[0]}, nil
+				},
+			}
+			md := &RateLimiterMiddleware{s: mockStore}
+
+			_, msg, code := md.getReachedLimit(ctx, tt.key, tt.limits)
+			if msg != tt.expectedMsg {
+				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("Expected code: %v, got: %v", tt.expectedCode, code)
+			}
+		})
+	}
+}
+
+func TestCheckAndBlock(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       *database.LimitResult
+		checkErr     error
+		expectedMsg  string
+		expectedCode int
+	}{
+		{
+			name:         "Limit Not Reached",
+			result:       &database.LimitResult{Allowed: true},
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+		{
+			name:         "Limit Reached",
+			result:       &database.LimitResult{Allowed: false, Limit: configs.Limit{Requests: 100, Window: time.Second}},
+			expectedMsg:  rateLimitMsg,
+			expectedCode: http.StatusTooManyRequests,
+		},
+		{
+			name:         "Blacklisted",
+			result:       &database.LimitResult{Allowed: false, BlackListed: true},
+			expectedMsg:  rateLimitMsg,
+			expectedCode: http.StatusTooManyRequests,
+		},
+		{
+			name:         "Error in CheckAndBlock",
+			checkErr:     fmt.Errorf("some error"),
+			expectedMsg:  internalErrMsg,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockConfig := &configs.Config{
+				BlockedTime: 300,
+			}
+			limits := []configs.Limit{{Requests: 100, Window: time.Second}}
+			mockStore := &MockAtomicStore{
+				CheckAndBlockFunc: func(ctx context.Context, blackListKey, key string, l []configs.Limit, blockedTime int64) (*database.LimitResult, error) {
+					if blackListKey != "blacklist@test-api-key" {
+						t.Errorf("Expected blacklist key: %v, got: %v", "blacklist@test-api-key", blackListKey)
+					}
+					if key != "requests@test-api-key" {
+						t.Errorf("Expected key: %v, got: %v", "requests@test-api-key", key)
+					}
+					if blockedTime != 300 {
+						t.Errorf("Expected blocked time: %v, got: %v", 300, blockedTime)
+					}
+					return tt.result, tt.checkErr
+				},
+			}
+			md := &RateLimiterMiddleware{s: mockStore}
+
+			msg, code := md.checkAndBlock(ctx, mockStore, "blacklist@test-api-key", "requests@test-api-key", limits, mockConfig.BlockedTime)
+			if msg != tt.expectedMsg {
+				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("Expected code: %v, got: %v", tt.expectedCode, code)
+			}
+		})
+	}
+}
+
+func TestShape(t *testing.T) {
+	tests := []struct {
+		name             string
+		wait             time.Duration
+		ok               bool
+		reserveErr       error
+		timeout          time.Duration
+		expectedMsg      string
+		expectedCode     int
+		expectedReleased bool
+	}{
+		{
+			name:         "Released immediately",
+			ok:           true,
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+		{
+			name:         "Released after waiting",
+			wait:         10 * time.Millisecond,
+			ok:           true,
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+		{
+			name:         "Queue full",
+			ok:           false,
+			expectedMsg:  rateLimitMsg,
+			expectedCode: http.StatusTooManyRequests,
+		},
+		{
+			name:             "Context expires while waiting",
+			wait:             time.Second,
+			ok:               true,
+			timeout:          10 * time.Millisecond,
+			expectedMsg:      rateLimitMsg,
+			expectedCode:     http.StatusTooManyRequests,
+			expectedReleased: true,
+		},
+		{
+			name:         "Error in Reserve",
+			reserveErr:   fmt.Errorf("some error"),
+			expectedMsg:  internalErrMsg,
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			released := false
+			mockStore := &MockShapingStore{
+				ReserveFunc: func(ctx context.Context, key string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error) {
+					if tt.reserveErr != nil {
+						return nil, 0, tt.reserveErr
+					}
+					return &database.LimitResult{Allowed: tt.ok, Limit: limits[0]}, tt.wait, nil
+				},
+				ReleaseFunc: func(ctx context.Context, key string, limits []configs.Limit) error {
+					if ctx.Err() != nil {
+						t.Errorf("Expected a live context, got: %v", ctx.Err())
 					}
-					return tt.reachedLimit, tt.hasReachedErr
+					released = true
+					return nil
 				},
 			}
 			md := &RateLimiterMiddleware{s: mockStore}
 
-			msg, code := md.getReachedLimit(ctx, tt.key, tt.limit)
+			msg, code := md.shape(ctx, ctx, mockStore, "requests@test-api-key", []configs.Limit{{Requests: 5, Window: time.Second}})
 			if msg != tt.expectedMsg {
 				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
 			}
 			if code != tt.expectedCode {
 				t.Errorf("Expected code: %v, got: %v", tt.expectedCode, code)
 			}
+			if released != tt.expectedReleased {
+				t.Errorf("Expected released: %v, got: %v", tt.expectedReleased, released)
+			}
 		})
 	}
 }
 
+func TestApplyFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		routes       []configs.RoutePolicy
+		expectedMsg  string
+		expectedCode int
+	}{
+		{
+			name:         "No Policy",
+			policy:       "",
+			expectedMsg:  internalErrMsg,
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "Fail Open",
+			policy:       configs.FailOpen,
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+		{
+			name:         "Fail Closed",
+			policy:       configs.FailClosed,
+			expectedMsg:  unavailableMsg,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Fall Back To Memory",
+			policy:       configs.FailToMemory,
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+		{
+			name:         "Route Policy",
+			policy:       configs.FailOpen,
+			routes:       []configs.RoutePolicy{{Pattern: "/", Limits: []configs.Limit{{Requests: 1, Window: time.Second}}, FailurePolicy: configs.FailClosed}},
+			expectedMsg:  unavailableMsg,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Route Without Policy",
+			policy:       configs.FailOpen,
+			routes:       []configs.RoutePolicy{{Pattern: "/", Limits: []configs.Limit{{Requests: 1, Window: time.Second}}}},
+			expectedMsg:  "",
+			expectedCode: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "http://example.com/", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.RemoteAddr = "192.168.1.1"
+			mockConfig := &configs.Config{
+				DefaultLimits: []configs.Limit{{Requests: 1, Window: time.Second}},
+				FailurePolicy: tt.policy,
+				RoutePolicies: tt.routes,
+			}
+			md := &RateLimiterMiddleware{s: &MockStore{}}
+			defer md.Close()
+
+			msg, code := md.applyFailurePolicy(req, mockConfig, md.matchRoute(req, mockConfig), "", "192.168.1.1", internalErrMsg, http.StatusInternalServerError)
+			if msg != tt.expectedMsg {
+				t.Errorf("Expected message: %v, got: %v", tt.expectedMsg, msg)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("Expected code: %v, got: %v", tt.expectedCode, code)
+			}
+		})
+	}
+}
+
+func TestCheckAccessLists(t *testing.T) {
+	mockConfig := &configs.Config{
+		Allowlist: configs.NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}),
+		Denylist:  configs.NewPrefixSet([]netip.Prefix{netip.MustParsePrefix("198.51.100.0/24"), netip.MustParsePrefix("10.6.6.6/32")}),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		expectedMsg  string
+		expectedCode int
+		expectedList bool
+	}{
+		{
+			name:         "Allowlisted",
+			remoteAddr:   "10.1.2.3:54321",
+			expectedMsg:  "",
+			expectedCode: 0,
+			expectedList: true,
+		},
+		{
+			name:         "Denylisted",
+			remoteAddr:   "198.51.100.7:54321",
+			expectedMsg:  forbiddenMsg,
+			expectedCode: http.StatusForbidden,
+			expectedList: true,
+		},
+		{
+			name:         "Denylist Wins",
+			remoteAddr:   "10.6.6.6:54321",
+			expectedMsg:  forbiddenMsg,
+			expectedCode: http.StatusForbidden,
+			expectedList: true,
+		},
+		{
+			name:         "Not Listed",
+			remoteAddr:   "203.0.113.1:54321",
+			expectedMsg:  "",
+			expectedCode: 0,
+			expectedList: false,
+		},
+		{
+			name:         "Invalid Remote Address",
+			remoteAddr:   "",
+			expectedMsg:  "",
+			expectedCode: 0,
+			expectedList: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "http://example.com", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.RemoteAddr = tt.remoteAddr
+
+			msg, code, listed := checkAccessLists(getClientIP(req, mockConfig.TrustedProxies), mockConfig)
+			if msg != tt.expectedMsg || code != tt.expectedCode || listed != tt.expectedList {
+				t.Errorf("Expected: %v %v %v, got: %v %v %v", tt.expectedMsg, tt.expectedCode, tt.expectedList, msg, code, listed)
+			}
+		})
+	}
+}
+
+func TestFallbackMiddlewareEnforcesLimits(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.RemoteAddr = "192.168.1.1"
+	mockConfig := &configs.Config{
+		DefaultLimits: []configs.Limit{{Requests: 1, Window: time.Minute}},
+		BlockedTime:   300,
+		FailurePolicy: configs.FailToMemory,
+	}
+	md := &RateLimiterMiddleware{s: &MockStore{}}
+	defer md.Close()
+
+	msg, _ := md.applyFailurePolicy(req, mockConfig, nil, "", "192.168.1.1", internalErrMsg, http.StatusInternalServerError)
+	if msg != "" {
+		t.Errorf("Expected first request to pass, got: %v", msg)
+	}
+	msg, code := md.applyFailurePolicy(req, mockConfig, nil, "", "192.168.1.1", internalErrMsg, http.StatusInternalServerError)
+	if msg != rateLimitMsg || code != http.StatusTooManyRequests {
+		t.Errorf("Expected second request to be limited, got: %v %v", msg, code)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	mockConfig := &configs.Config{
+		DefaultLimits: []configs.Limit{{Requests: 1, Window: time.Second}},
+		FailurePolicy: configs.FailOpen,
+	}
+	store := &MockBreakerStore{Open: true}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	md := NewRateLimiterMiddleware(store, WithConfig(func() *configs.Config { return mockConfig }), WithLogger(logger))
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.RemoteAddr = "192.168.1.1:54321"
+	if msg, _ := md.CheckRateLimit(req); msg != "" {
+		t.Fatalf("Expected request to pass, got: %v", msg)
+	}
+	if !strings.Contains(buf.String(), "letting request through") {
+		t.Errorf("Expected the failure policy to be logged, got: %q", buf.String())
+	}
+
+	md = NewRateLimiterMiddleware(store, WithConfig(func() *configs.Config { return mockConfig }))
+	if msg, _ := md.CheckRateLimit(req); msg != "" {
+		t.Fatalf("Expected request to pass, got: %v", msg)
+	}
+}
+
 func TestAddToBlackList(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -394,10 +776,68 @@ func TestAddToBlackList(t *testing.T) {
 	}
 }
 
+func TestEnforceBlockedTime(t *testing.T) {
+	tests := []struct {
+		name          string
+		blockedTime   int64
+		exactRetry    bool
+		expectedSaves []int64
+	}{
+		{
+			name:          "Never blocked",
+			blockedTime:   0,
+			expectedSaves: nil,
+		},
+		{
+			name:          "Blocked",
+			blockedTime:   300,
+			expectedSaves: []int64{300},
+		},
+		{
+			name:          "Exact retry after",
+			blockedTime:   300,
+			exactRetry:    true,
+			expectedSaves: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saves []int64
+			mockStore := &MockStore{
+				GetFunc: func(ctx context.Context, key string) (string, error) {
+					return "", nil
+				},
+				HasReachedLimitFunc: func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+					return &database.LimitResult{Allowed: false, Limit: limits[0]}, nil
+				},
+				SaveFunc: func(ctx context.Context, key, value string, ttl int64) error {
+					saves = append(saves, ttl)
+					return nil
+				},
+			}
+			md := &RateLimiterMiddleware{s: mockStore}
+			if tt.exactRetry {
+				md.s = &MockExactRetryStore{MockStore: *mockStore}
+			}
+			policy := configs.KeyPolicy{Limits: []configs.Limit{{Requests: 10, Window: time.Second}}, BlockedTime: tt.blockedTime}
+
+			ctx := context.Background()
+			_, code := md.enforce(ctx, ctx, "blacklist@{key}", "requests@{key}", policy)
+			if code != http.StatusTooManyRequests {
+				t.Errorf("Expected code: %v, got: %v", http.StatusTooManyRequests, code)
+			}
+			if !reflect.DeepEqual(saves, tt.expectedSaves) {
+				t.Errorf("Expected saves: %v, got: %v", tt.expectedSaves, saves)
+			}
+		})
+	}
+}
+
 // MockStore is a mock implementation of the store interface used for testing
 type MockStore struct {
 	GetFunc             func(ctx context.Context, key string) (string, error)
-	HasReachedLimitFunc func(ctx context.Context, key string, limit int64) (bool, error)
+	HasReachedLimitFunc func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error)
 	SaveFunc            func(ctx context.Context, key, value string, ttl int64) error
 }
 
@@ -405,10 +845,159 @@ func (m *MockStore) Get(ctx context.Context, key string) (string, error) {
 	return m.GetFunc(ctx, key)
 }
 
-func (m *MockStore) HasReachedLimit(ctx context.Context, key string, limit int64) (bool, error) {
-	return m.HasReachedLimitFunc(ctx, key, limit)
+func (m *MockStore) HasReachedLimit(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+	return m.HasReachedLimitFunc(ctx, key, limits)
 }
 
 func (m *MockStore) Save(ctx context.Context, key, value string, ttl int64) error {
 	return m.SaveFunc(ctx, key, value, ttl)
 }
+
+// MockShapingStore is a mock implementation of the shaping store interface used for testing
+type MockShapingStore struct {
+	MockStore
+	ReserveFunc func(ctx context.Context, key string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error)
+	ReleaseFunc func(ctx context.Context, key string, limits []configs.Limit) error
+}
+
+func (m *MockShapingStore) Reserve(ctx context.Context, key string, limits []configs.Limit, deadline time.Time) (*database.LimitResult, time.Duration, error) {
+	return m.ReserveFunc(ctx, key, limits, deadline)
+}
+
+func (m *MockShapingStore) Release(ctx context.Context, key string, limits []configs.Limit) error {
+	return m.ReleaseFunc(ctx, key, limits)
+}
+
+// MockTTLStore is a mock implementation of the TTL store interface used for testing
+type MockTTLStore struct {
+	MockStore
+	TTLFunc func(ctx context.Context, key string) (time.Duration, error)
+}
+
+func (m *MockTTLStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return m.TTLFunc(ctx, key)
+}
+
+// MockExactRetryStore is a mock implementation of the exact retry store interface used for testing
+type MockExactRetryStore struct {
+	MockStore
+}
+
+func (m *MockExactRetryStore) ExactRetryAfter() bool {
+	return true
+}
+
+// MockBreakerStore is a mock implementation of the circuit breaker store interface used for testing
+type MockBreakerStore struct {
+	MockStore
+	Open bool
+}
+
+func (m *MockBreakerStore) CircuitOpen() bool {
+	return m.Open
+}
+
+// MockAtomicStore is a mock implementation of the atomic store interface used for testing
+type MockAtomicStore struct {
+	MockStore
+	CheckAndBlockFunc func(ctx context.Context, blackListKey, key string, limits []configs.Limit, blockedTime int64) (*database.LimitResult, error)
+}
+
+func (m *MockAtomicStore) CheckAndBlock(ctx context.Context, blackListKey, key string, limits []configs.Limit, blockedTime int64) (*database.LimitResult, error) {
+	return m.CheckAndBlockFunc(ctx, blackListKey, key, limits, blockedTime)
+}
+
+func TestCheckRateLimitTierAlgorithm(t *testing.T) {
+	var usedKeys []string
+	newStore := func(name string) *MockStore {
+		return &MockStore{
+			GetFunc: func(ctx context.Context, key string) (string, error) {
+				return "", nil
+			},
+			HasReachedLimitFunc: func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+				usedKeys = append(usedKeys, name+" "+key)
+				return &database.LimitResult{Allowed: true}, nil
+			},
+		}
+	}
+	var created []string
+	md := NewRateLimiterMiddleware(newStore("main")).WithAlgorithms(func(algorithm string) RateLimiterStrategy {
+		created = append(created, algorithm)
+		return newStore(algorithm)
+	})
+	mockConfig := &configs.Config{
+		DefaultLimits: []configs.Limit{{Requests: 10, Window: time.Second}},
+		Tiers: map[string]configs.Tier{
+			"free": {Limits: []configs.Limit{{Requests: 10, Window: time.Minute}}, Algorithm: configs.FixedWindow},
+			"pro":  {Limits: []configs.Limit{{Requests: 100, Window: time.Minute}}, Algorithm: configs.GCRA},
+		},
+		ApiKeyTiers: map[string]string{"free-key": "free", "pro-key": "pro"},
+	}
+
+	for _, apiKey := range []string{"", "free-key", "pro-key", "pro-key"} {
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		req.Header.Set("API_KEY", apiKey)
+		req.RemoteAddr = "192.168.1.1:54321"
+		apiKey, clientIP := getCredentials(req, nil)
+		if msg, _ := md.checkRateLimit(req, mockConfig, nil, apiKey, clientIP); msg != "" {
+			t.Fatalf("Expected request to pass, got: %v", msg)
+		}
+	}
+
+	expectedKeys := []string{
+		"main requests@{192.168.1.1}",
+		"main requests@{free-key}",
+		"gcra requests@{pro-key}:gcra",
+		"gcra requests@{pro-key}:gcra",
+	}
+	if !reflect.DeepEqual(usedKeys, expectedKeys) {
+		t.Errorf("Expected keys: %v, got: %v", expectedKeys, usedKeys)
+	}
+	if !reflect.DeepEqual(created, []string{configs.GCRA}) {
+		t.Errorf("Expected a single gcra strategy, got: %v", created)
+	}
+}
+
+func TestCheckRateLimitTierAlgorithmBreaker(t *testing.T) {
+	mainStore := &MockBreakerStore{MockStore: MockStore{
+		GetFunc: func(ctx context.Context, key string) (string, error) {
+			return "", nil
+		},
+		HasReachedLimitFunc: func(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+			return &database.LimitResult{Allowed: true}, nil
+		},
+	}}
+	gcraStore := &MockBreakerStore{MockStore: mainStore.MockStore, Open: true}
+	md := NewRateLimiterMiddleware(mainStore).WithAlgorithms(func(algorithm string) RateLimiterStrategy {
+		return gcraStore
+	})
+	mockConfig := &configs.Config{
+		DefaultLimits: []configs.Limit{{Requests: 10, Window: time.Second}},
+		Tiers: map[string]configs.Tier{
+			"pro": {Limits: []configs.Limit{{Requests: 100, Window: time.Minute}}, Algorithm: configs.GCRA},
+		},
+		ApiKeyTiers:   map[string]string{"pro-key": "pro"},
+		FailurePolicy: configs.FailClosed,
+	}
+	md.config = func() *configs.Config { return mockConfig }
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		expectedCode int
+	}{
+		{name: "Main Strategy Closed", apiKey: "", expectedCode: 0},
+		{name: "Tier Strategy Open", apiKey: "pro-key", expectedCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("API_KEY", tt.apiKey)
+			req.RemoteAddr = "192.168.1.1:54321"
+			if _, code := md.CheckRateLimit(req); code != tt.expectedCode {
+				t.Errorf("Expected status code: %v, got: %v", tt.expectedCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+)
+
+// Decision describes why the middleware rejected a request. Limit is the
+// limit that was exceeded, if any, among the Limits the request was counted
+// against.
+type Decision struct {
+	StatusCode  int
+	Message     string
+	BlackListed bool
+	Limits      []configs.Limit
+	Limit       configs.Limit
+	RetryAfter  time.Duration
+	ResetAfter  time.Duration
+}
+
+// RejectionHandler writes the response to a rejected request. The RateLimit
+// and Retry-After headers are already set on w when it is called.
+type RejectionHandler func(w http.ResponseWriter, r *http.Request, decision Decision)
+
+// WithOnLimited renders requests rejected for exceeding their limits or
+// coming from a blacklisted client.
+func WithOnLimited(handler RejectionHandler) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.onLimited = handler
+	}
+}
+
+// WithOnInvalidKey renders requests carrying an unknown API key.
+func WithOnInvalidKey(handler RejectionHandler) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.onInvalidKey = handler
+	}
+}
+
+// WithOnError renders requests that could not be rate limited because the
+// store failed or is unavailable.
+func WithOnError(handler RejectionHandler) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.onError = handler
+	}
+}
+
+// WithOnDenied renders requests from clients in the denylist.
+func WithOnDenied(handler RejectionHandler) Option {
+	return func(md *RateLimiterMiddleware) {
+		md.onDenied = handler
+	}
+}
+
+func newDecision(errMsg string, statusCode int, q *quota) Decision {
+	decision := Decision{StatusCode: statusCode, Message: errMsg}
+	if q != nil {
+		decision.Limits = q.limits
+	}
+	if statusCode == http.StatusTooManyRequests && q != nil && q.result != nil {
+		decision.BlackListed = q.result.BlackListed
+		decision.Limit = q.result.Limit
+		decision.RetryAfter = q.retryAfter()
+		decision.ResetAfter = q.result.ResetAfter
+	}
+	return decision
+}
+
+func (md *RateLimiterMiddleware) rejectionHandler(statusCode int) RejectionHandler {
+	var handler RejectionHandler
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		handler = md.onLimited
+	case http.StatusUnauthorized:
+		handler = md.onInvalidKey
+	case http.StatusInternalServerError, http.StatusServiceUnavailable:
+		handler = md.onError
+	case http.StatusForbidden:
+		handler = md.onDenied
+	}
+	if handler == nil {
+		return md.writeDecision
+	}
+	return handler
+}
+
+// writeDecision renders decision as configured by ERROR_FORMAT.
+func (md *RateLimiterMiddleware) writeDecision(w http.ResponseWriter, r *http.Request, decision Decision) {
+	writeRejection(w, r, md.getConfig(), md.log(), newRejection(decision))
+}
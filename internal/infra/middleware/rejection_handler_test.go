@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+)
+
+func TestRejectionHandler(t *testing.T) {
+	var called string
+	handler := func(name string) RejectionHandler {
+		return func(w http.ResponseWriter, r *http.Request, decision Decision) {
+			called = name
+		}
+	}
+	md := NewRateLimiterMiddleware(&MockStore{},
+		WithOnLimited(handler("limited")),
+		WithOnInvalidKey(handler("invalid key")),
+		WithOnError(handler("error")),
+		WithOnDenied(handler("denied")),
+	)
+
+	tests := []struct {
+		name       string
+		statusCode int
+		expected   string
+	}{
+		{name: "Limited", statusCode: http.StatusTooManyRequests, expected: "limited"},
+		{name: "Invalid key", statusCode: http.StatusUnauthorized, expected: "invalid key"},
+		{name: "Store error", statusCode: http.StatusInternalServerError, expected: "error"},
+		{name: "Store unavailable", statusCode: http.StatusServiceUnavailable, expected: "error"},
+		{name: "Denied", statusCode: http.StatusForbidden, expected: "denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = ""
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			md.rejectionHandler(tt.statusCode)(httptest.NewRecorder(), req, Decision{StatusCode: tt.statusCode})
+			if called != tt.expected {
+				t.Errorf("Expected handler: %v, got: %v", tt.expected, called)
+			}
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		config := &configs.Config{ErrorFormat: configs.JSONFormat}
+		md := NewRateLimiterMiddleware(&MockStore{}, WithConfig(func() *configs.Config { return config }))
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		rec := httptest.NewRecorder()
+
+		md.rejectionHandler(http.StatusTooManyRequests)(rec, req, Decision{StatusCode: http.StatusTooManyRequests, Message: rateLimitMsg})
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected content type: application/json, got: %v", contentType)
+		}
+	})
+}
+
+func TestNewDecision(t *testing.T) {
+	limits := []configs.Limit{{Requests: 10, Window: time.Second}, {Requests: 100, Window: time.Hour}}
+	q := &quota{
+		limits: limits,
+		result: &database.LimitResult{Allowed: false, Limit: limits[1], RetryAfter: time.Minute, ResetAfter: time.Minute},
+	}
+
+	tests := []struct {
+		name       string
+		errMsg     string
+		statusCode int
+		quota      *quota
+		expected   Decision
+	}{
+		{
+			name:       "Limited",
+			errMsg:     rateLimitMsg,
+			statusCode: http.StatusTooManyRequests,
+			quota:      q,
+			expected: Decision{
+				StatusCode: http.StatusTooManyRequests,
+				Message:    rateLimitMsg,
+				Limits:     limits,
+				Limit:      limits[1],
+				RetryAfter: time.Minute,
+				ResetAfter: time.Minute,
+			},
+		},
+		{
+			name:       "Store error",
+			errMsg:     internalErrMsg,
+			statusCode: http.StatusInternalServerError,
+			quota:      q,
+			expected:   Decision{StatusCode: http.StatusInternalServerError, Message: internalErrMsg, Limits: limits},
+		},
+		{
+			name:       "Invalid key",
+			errMsg:     invalidKey,
+			statusCode: http.StatusUnauthorized,
+			quota:      &quota{},
+			expected:   Decision{StatusCode: http.StatusUnauthorized, Message: invalidKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := newDecision(tt.errMsg, tt.statusCode, tt.quota)
+			if !reflect.DeepEqual(decision, tt.expected) {
+				t.Errorf("Expected decision: %+v, got: %+v", tt.expected, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/go-chi/chi/v5"
+)
+
+// routeMatcher finds the policy of a request with a chi router of its own,
+// so the most specific pattern wins just as it does when chi routes the
+// request, and a policy for the request's method wins over one for every
+// method on the same pattern.
+type routeMatcher struct {
+	mux      *chi.Mux
+	policies map[string]configs.RoutePolicy
+}
+
+func newRouteMatcher(policies []configs.RoutePolicy) (matcher *routeMatcher, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			matcher, err = nil, fmt.Errorf("invalid route policy: %v", r)
+		}
+	}()
+
+	matcher = &routeMatcher{mux: chi.NewMux(), policies: make(map[string]configs.RoutePolicy)}
+	found := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, policy := range policies {
+		if policy.Method == "" {
+			matcher.mux.Handle(policy.Pattern, found)
+			matcher.policies[policy.String()] = policy
+		}
+	}
+	for _, policy := range policies {
+		if policy.Method != "" {
+			matcher.mux.Method(policy.Method, policy.Pattern, found)
+			matcher.policies[policy.String()] = policy
+		}
+	}
+	return matcher, nil
+}
+
+func (m *routeMatcher) match(r *http.Request) (configs.RoutePolicy, bool) {
+	if len(m.policies) == 0 {
+		return configs.RoutePolicy{}, false
+	}
+	rctx := chi.NewRouteContext()
+	if !m.mux.Match(rctx, r.Method, r.URL.Path) {
+		return configs.RoutePolicy{}, false
+	}
+	pattern := rctx.RoutePattern()
+	if policy, ok := m.policies[r.Method+" "+pattern]; ok {
+		return policy, true
+	}
+	policy, ok := m.policies[pattern]
+	return policy, ok
+}
+
+// routeMatcherCache builds the matcher of a configuration once and keeps it
+// for as long as that configuration is in use. Requests only load the cached
+// matcher; it is rebuilt when a reload swaps the configuration.
+type routeMatcherCache struct {
+	current atomic.Pointer[cachedRouteMatcher]
+}
+
+type cachedRouteMatcher struct {
+	config  *configs.Config
+	matcher *routeMatcher
+}
+
+func (c *routeMatcherCache) get(config *configs.Config) *routeMatcher {
+	if cached := c.current.Load(); cached != nil && cached.config == config {
+		return cached.matcher
+	}
+	matcher, err := newRouteMatcher(config.RoutePolicies)
+	if err != nil {
+		fmt.Println("Error loading route policies:", err)
+		matcher, _ = newRouteMatcher(nil)
+	}
+	c.current.Store(&cachedRouteMatcher{config: config, matcher: matcher})
+	return matcher
+}
+
+// matchRoute returns the policy of the route of r, or nil when it has none.
+func (md *RateLimiterMiddleware) matchRoute(r *http.Request, config *configs.Config) *configs.RoutePolicy {
+	if route, ok := md.routes.get(config).match(r); ok {
+		return &route
+	}
+	return nil
+}
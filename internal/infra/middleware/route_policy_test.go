@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	policies := []configs.RoutePolicy{
+		{Method: "POST", Pattern: "/login", Limits: []configs.Limit{{Requests: 5, Window: time.Minute}}},
+		{Pattern: "/login", Limits: []configs.Limit{{Requests: 50, Window: time.Minute}}},
+		{Pattern: "/users/*", Limits: []configs.Limit{{Requests: 10, Window: time.Second}}},
+		{Method: "GET", Pattern: "/users/{id}", Limits: []configs.Limit{{Requests: 20, Window: time.Second}}},
+		{Pattern: "/users/me", Limits: []configs.Limit{{Requests: 1, Window: time.Second}}},
+	}
+	matcher, err := newRouteMatcher(policies)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"POST", "/login", "POST /login"},
+		{"GET", "/login", "/login"},
+		{"GET", "/users/me", "/users/me"},
+		{"GET", "/users/42", "GET /users/{id}"},
+		{"DELETE", "/users/42", "/users/*"},
+		{"GET", "/users/42/posts", "/users/*"},
+		{"GET", "/ip", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+			policy, ok := matcher.match(req)
+			assert.Equal(t, tt.expected != "", ok)
+			if ok {
+				assert.Equal(t, tt.expected, policy.String())
+			}
+		})
+	}
+}
+
+func TestRouteMatcherInvalidPattern(t *testing.T) {
+	_, err := newRouteMatcher([]configs.RoutePolicy{{Pattern: "/users/{id}/{id}"}})
+	assert.Error(t, err)
+}
+
+func TestRouteMatcherCache(t *testing.T) {
+	var cache routeMatcherCache
+	first := &configs.Config{RoutePolicies: []configs.RoutePolicy{{Pattern: "/login"}}}
+	second := &configs.Config{}
+
+	matcher := cache.get(first)
+	assert.Same(t, matcher, cache.get(first))
+	assert.NotSame(t, matcher, cache.get(second))
+}
+
+func TestCheckRateLimitRoutePolicy(t *testing.T) {
+	mockConfig := &configs.Config{
+		DefaultLimits: []configs.Limit{{Requests: 10, Window: time.Second}},
+		RoutePolicies: []configs.RoutePolicy{
+			{Method: "POST", Pattern: "/login", Limits: []configs.Limit{{Requests: 5, Window: time.Minute}}},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedKey    string
+		expectedLimits []configs.Limit
+	}{
+		{"matching route", "POST", "/login", "requests@{192.168.1.1}:POST /login", mockConfig.RoutePolicies[0].Limits},
+		{"unmatched route", "GET", "/ip", "requests@{192.168.1.1}", mockConfig.DefaultLimits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var key string
+			var limits []configs.Limit
+			md := &RateLimiterMiddleware{s: &MockStore{
+				GetFunc: func(ctx context.Context, key string) (string, error) {
+					return "", nil
+				},
+				HasReachedLimitFunc: func(ctx context.Context, k string, l []configs.Limit) (*database.LimitResult, error) {
+					key, limits = k, l
+					return &database.LimitResult{Allowed: true}, nil
+				},
+			}}
+			req, _ := http.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+			req.RemoteAddr = "192.168.1.1:54321"
+
+			apiKey, clientIP := getCredentials(req, nil)
+			msg, _ := md.checkRateLimit(req, mockConfig, md.matchRoute(req, mockConfig), apiKey, clientIP)
+			assert.Empty(t, msg)
+			assert.Equal(t, tt.expectedKey, key)
+			assert.Equal(t, tt.expectedLimits, limits)
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package webserver
 
 import (
+	"log/slog"
 	"net/http"
 
-	md "github.com/carlosmeds/rate-limiter/internal/infra/middleware"
+	"github.com/carlosmeds/rate-limiter/pkg/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -27,11 +28,17 @@ func (s *WebServer) AddHandler(path string, handler http.HandlerFunc) {
 }
 
 func (s *WebServer) Start() {
-	strategy := md.NewRateLimiterStrategy()
-	rl := md.NewRateLimiterMiddleware(strategy)
+	limiter, err := ratelimit.New(
+		ratelimit.WithEnvConfig(),
+		ratelimit.WithLogger(slog.Default()),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer limiter.Close()
 
 	s.Router.Use(middleware.Logger)
-	s.Router.Use(rl.RateLimiter)
+	limiter.Use(s.Router)
 	for path, handler := range s.Handlers {
 		s.Router.Handle(path, handler)
 	}
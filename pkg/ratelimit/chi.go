@@ -0,0 +1,14 @@
+package ratelimit
+
+import "github.com/go-chi/chi/v5"
+
+// Use limits every request handled by router.
+func (l *Limiter) Use(router chi.Router) {
+	router.Use(l.Handler)
+}
+
+// With returns a router whose routes are limited, leaving the other routes of
+// router alone.
+func (l *Limiter) With(router chi.Router) chi.Router {
+	return router.With(l.Handler)
+}
@@ -0,0 +1,14 @@
+package ratelimit
+
+import "net/http"
+
+// Handler lets the requests that fit their limits through to next and
+// rejects the others. Every response carries the RateLimit headers.
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return l.md.RateLimiter(next)
+}
+
+// HandlerFunc is Handler for a single handler function.
+func (l *Limiter) HandlerFunc(next http.HandlerFunc) http.HandlerFunc {
+	return l.Handler(next).ServeHTTP
+}
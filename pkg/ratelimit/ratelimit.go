@@ -0,0 +1,262 @@
+// Package ratelimit limits the requests an HTTP server accepts per API key or
+// client IP, with the same limits, stores and responses as the rate limiter
+// server, for services that embed it.
+//
+//	limiter, err := ratelimit.New(
+//		ratelimit.WithLimits("10/s+1000/h"),
+//		ratelimit.WithAPIKey("abc", "100/s"),
+//		ratelimit.WithRoute("POST /login", "5/m"),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer limiter.Close()
+//	http.ListenAndServe(":8080", limiter.Handler(mux))
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/middleware"
+)
+
+const (
+	FixedWindow          = configs.FixedWindow
+	TokenBucket          = configs.TokenBucket
+	SlidingWindowLog     = configs.SlidingWindowLog
+	SlidingWindowCounter = configs.SlidingWindowCounter
+	GCRA                 = configs.GCRA
+	LeakyBucket          = configs.LeakyBucket
+)
+
+// Limiter is an HTTP middleware rejecting the requests of clients that
+// exceed their limits.
+type Limiter struct {
+	md    *middleware.RateLimiterMiddleware
+	close func()
+}
+
+type settings struct {
+	config   *configs.Config
+	env      bool
+	strategy Strategy
+	options  []middleware.Option
+}
+
+// Option configures a Limiter.
+type Option func(*settings) error
+
+// New creates a Limiter. Requests are counted in memory with a fixed window
+// unless WithStrategy is given, and WithLimits is required unless the
+// configuration comes from WithEnvConfig. Close the Limiter once it is no
+// longer used.
+func New(options ...Option) (*Limiter, error) {
+	s := &settings{config: &configs.Config{
+		ApiKeyLimits:       make(map[string][]configs.Limit),
+		ApiKeyBlockedTimes: make(map[string]int64),
+	}}
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.env {
+		if _, err := configs.LoadConfig(); err != nil {
+			return nil, fmt.Errorf("ratelimit: %w", err)
+		}
+		if s.strategy != nil {
+			md := middleware.NewRateLimiterMiddleware(middlewareStrategy(s.strategy), append(s.options, middleware.WithConfig(configs.GetConfig))...)
+			return &Limiter{md: md, close: closeStrategy(s.strategy)}, nil
+		}
+		strategy, algorithms, closeEnvStrategy := envStrategy()
+		md := middleware.NewRateLimiterMiddleware(strategy, append(s.options, middleware.WithConfig(configs.GetConfig))...)
+		if algorithms != nil {
+			md.WithAlgorithms(algorithms)
+		}
+		return &Limiter{md: md, close: closeEnvStrategy}, nil
+	}
+
+	if len(s.config.DefaultLimits) == 0 {
+		return nil, errors.New("ratelimit: no default limit, use WithLimits")
+	}
+	strategy := s.strategy
+	if strategy == nil {
+		strategy = NewMemoryStrategy()
+	}
+	config := s.config
+	md := middleware.NewRateLimiterMiddleware(middlewareStrategy(strategy), append(s.options, middleware.WithConfig(func() *configs.Config { return config }))...)
+	return &Limiter{md: md, close: closeStrategy(strategy)}, nil
+}
+
+// Close stops the background work of the Limiter and of the memory strategy
+// it uses, such as cleaning up expired counters. Redis clients given to
+// NewRedisStrategy are left open.
+func (l *Limiter) Close() {
+	l.md.Close()
+	if l.close != nil {
+		l.close()
+	}
+}
+
+func closeStrategy(strategy Strategy) func() {
+	if s, ok := strategy.(*storeStrategy); ok {
+		return s.close
+	}
+	return nil
+}
+
+// WithLimits sets the limits of clients without an API key, such as
+// "10/s+1000/h".
+func WithLimits(limits string) Option {
+	return func(s *settings) error {
+		parsed, err := configs.ParseLimits(limits)
+		if err != nil {
+			return fmt.Errorf("ratelimit: %w", err)
+		}
+		s.config.DefaultLimits = parsed
+		return nil
+	}
+}
+
+// WithAPIKey accepts requests carrying apiKey in their API_KEY header and
+// counts them against limits. Requests with an API key that was not given
+// are rejected.
+func WithAPIKey(apiKey, limits string) Option {
+	return func(s *settings) error {
+		parsed, err := configs.ParseLimits(limits)
+		if err != nil {
+			return fmt.Errorf("ratelimit: API key %q: %w", apiKey, err)
+		}
+		s.config.ApiKeyLimits[apiKey] = parsed
+		return nil
+	}
+}
+
+// WithRoute replaces the limits of every client on route, such as
+// "POST /login" or "/admin/*" for every method.
+func WithRoute(route, limits string) Option {
+	return func(s *settings) error {
+		policy, err := configs.NewRoutePolicy(route, limits)
+		if err != nil {
+			return fmt.Errorf("ratelimit: %w", err)
+		}
+		s.config.RoutePolicies = append(s.config.RoutePolicies, policy)
+		return nil
+	}
+}
+
+// WithBlockedTime rejects every request of a client for d once it exceeds
+// one of its limits.
+func WithBlockedTime(d time.Duration) Option {
+	return func(s *settings) error {
+		s.config.BlockedTime = int64(d / time.Second)
+		return nil
+	}
+}
+
+// WithTrustedProxies reads the client IP from forwarding headers when the
+// request comes from one of prefixes, such as "10.0.0.0/8,192.168.0.1".
+func WithTrustedProxies(prefixes string) Option {
+	return func(s *settings) error {
+		parsed, err := configs.ParsePrefixes(prefixes)
+		if err != nil {
+			return fmt.Errorf("ratelimit: %w", err)
+		}
+		s.config.TrustedProxies = parsed
+		return nil
+	}
+}
+
+// WithDecisionTimeout bounds the time spent on the store for one request.
+func WithDecisionTimeout(d time.Duration) Option {
+	return func(s *settings) error {
+		s.config.DecisionTimeout = d
+		return nil
+	}
+}
+
+// WithFailurePolicy decides what happens to requests when the store fails:
+// "open" lets them through, "closed" rejects them with status code 503 and
+// "memory" limits them in memory.
+func WithFailurePolicy(policy string) Option {
+	return func(s *settings) error {
+		switch policy {
+		case configs.FailOpen, configs.FailClosed, configs.FailToMemory:
+			s.config.FailurePolicy = policy
+			return nil
+		}
+		return fmt.Errorf("ratelimit: unknown failure policy %q", policy)
+	}
+}
+
+// WithErrorFormat sets the body of rejected requests: "text", "json" or
+// "problem" for RFC 9457 problem details.
+func WithErrorFormat(format string) Option {
+	return func(s *settings) error {
+		switch format {
+		case configs.TextFormat, configs.JSONFormat, configs.ProblemFormat:
+			s.config.ErrorFormat = format
+			return nil
+		}
+		return fmt.Errorf("ratelimit: unknown error format %q", format)
+	}
+}
+
+// WithStrategy counts requests with strategy, such as one created by
+// NewRedisStrategy or a Strategy of the service's own.
+func WithStrategy(strategy Strategy) Option {
+	return func(s *settings) error {
+		s.strategy = strategy
+		return nil
+	}
+}
+
+// WithEnvConfig reads every setting from the env file and POLICY_FILE like
+// the server does, instead of from the other options. New fails when they
+// cannot be loaded.
+// Unless WithStrategy is given, requests are also counted in the store and
+// with the algorithms configured there.
+func WithEnvConfig() Option {
+	return func(s *settings) error {
+		s.env = true
+		return nil
+	}
+}
+
+// WithOnLimited renders requests rejected for exceeding their limits.
+func WithOnLimited(handler RejectionHandler) Option {
+	return middlewareOption(middleware.WithOnLimited(rejectionHandler(handler)))
+}
+
+// WithOnInvalidKey renders requests carrying an unknown API key.
+func WithOnInvalidKey(handler RejectionHandler) Option {
+	return middlewareOption(middleware.WithOnInvalidKey(rejectionHandler(handler)))
+}
+
+// WithOnError renders requests the store could not decide on.
+func WithOnError(handler RejectionHandler) Option {
+	return middlewareOption(middleware.WithOnError(rejectionHandler(handler)))
+}
+
+// WithOnDenied renders requests from clients in the denylist.
+func WithOnDenied(handler RejectionHandler) Option {
+	return middlewareOption(middleware.WithOnDenied(rejectionHandler(handler)))
+}
+
+// WithLogger logs rejected requests and store failures to logger. Nothing is
+// logged without it.
+func WithLogger(logger *slog.Logger) Option {
+	return middlewareOption(middleware.WithLogger(logger))
+}
+
+func middlewareOption(option middleware.Option) Option {
+	return func(s *settings) error {
+		s.options = append(s.options, option)
+		return nil
+	}
+}
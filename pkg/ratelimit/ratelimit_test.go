@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/internal/infra/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func serve(handler http.Handler, method, path, apiKey string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if apiKey != "" {
+		r.Header.Set("API_KEY", apiKey)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		method   string
+		path     string
+		apiKey   string
+		requests int
+		want     int
+	}{
+		{"Allowed", []Option{WithLimits("2/m")}, "GET", "/", "", 2, http.StatusOK},
+		{"Limited", []Option{WithLimits("2/m")}, "GET", "/", "", 3, http.StatusTooManyRequests},
+		{"API key", []Option{WithLimits("1/m"), WithAPIKey("abc", "3/m")}, "GET", "/", "abc", 3, http.StatusOK},
+		{"Unknown API key", []Option{WithLimits("1/m")}, "GET", "/", "xyz", 1, http.StatusUnauthorized},
+		{"Route", []Option{WithLimits("5/m"), WithRoute("POST /login", "1/m")}, "POST", "/login", "", 2, http.StatusTooManyRequests},
+		{"Other route", []Option{WithLimits("5/m"), WithRoute("POST /login", "1/m")}, "GET", "/login", "", 2, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := New(tt.options...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			handler := limiter.Handler(ok)
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				w = serve(handler, tt.method, tt.path, tt.apiKey)
+			}
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterHeaders(t *testing.T) {
+	limiter, err := New(WithLimits("1/m"), WithBlockedTime(time.Hour))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	handler := limiter.HandlerFunc(ok)
+
+	w := serve(handler, "GET", "/", "")
+	if got := w.Header().Get("RateLimit-Policy"); got != "1;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want %q", got, "1;w=60")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, "0")
+	}
+
+	w = serve(handler, "GET", "/", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3600" {
+		t.Errorf("Retry-After = %q, want %q", got, "3600")
+	}
+}
+
+func TestLimiterCallbacks(t *testing.T) {
+	var decision Decision
+	limiter, err := New(
+		WithLimits("1/m"),
+		WithOnInvalidKey(func(w http.ResponseWriter, r *http.Request, d Decision) {
+			decision = d
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	w := serve(limiter.Handler(ok), "GET", "/", "xyz")
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+	if decision.StatusCode != http.StatusUnauthorized {
+		t.Errorf("decision status = %d, want %d", decision.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+// countingStrategy counts requests against the first limit only, without
+// ever resetting.
+type countingStrategy struct {
+	counts map[string]int64
+}
+
+func (s *countingStrategy) HasReachedLimit(ctx context.Context, key string, limits []Limit) (*Result, error) {
+	s.counts[key]++
+	limit := limits[0]
+	if s.counts[key] > limit.Requests {
+		return &Result{Allowed: false, Limit: limit, RetryAfter: limit.Window, ResetAfter: limit.Window}, nil
+	}
+	return &Result{Allowed: true, Limit: limit, Remaining: limit.Requests - s.counts[key], ResetAfter: limit.Window}, nil
+}
+
+func (s *countingStrategy) Get(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
+func (s *countingStrategy) Save(ctx context.Context, key, value string, ttl int64) error {
+	return nil
+}
+
+func TestLimiterCustomStrategy(t *testing.T) {
+	var decision Decision
+	limiter, err := New(
+		WithLimits("1/m"),
+		WithStrategy(&countingStrategy{counts: make(map[string]int64)}),
+		WithOnLimited(func(w http.ResponseWriter, r *http.Request, d Decision) {
+			decision = d
+			w.WriteHeader(d.StatusCode)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer limiter.Close()
+	handler := limiter.Handler(ok)
+
+	if w := serve(handler, "GET", "/", ""); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	w := serve(handler, "GET", "/", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want %q", got, "60")
+	}
+	want := Limit{Requests: 1, Window: time.Minute}
+	if decision.Limit != want || len(decision.Limits) != 1 || decision.Limits[0] != want {
+		t.Errorf("decision limits = %v %v, want %v", decision.Limit, decision.Limits, want)
+	}
+}
+
+func TestLimiterClose(t *testing.T) {
+	limiter, err := New(WithLimits("1/m"), WithFailurePolicy("memory"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	limiter.Close()
+	limiter.Close()
+}
+
+func TestLimiterChi(t *testing.T) {
+	limiter, err := New(WithLimits("1/m"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	router := chi.NewRouter()
+	router.Get("/public", ok)
+	limiter.With(router).Get("/limited", ok)
+
+	for i := 0; i < 2; i++ {
+		serve(router, "GET", "/public", "")
+		serve(router, "GET", "/limited", "")
+	}
+	if w := serve(router, "GET", "/public", ""); w.Code != http.StatusOK {
+		t.Errorf("public status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serve(router, "GET", "/limited", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("limited status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		wantErr bool
+	}{
+		{"Limits", []Option{WithLimits("10/s")}, false},
+		{"No limits", nil, true},
+		{"Invalid limits", []Option{WithLimits("10/x")}, true},
+		{"Invalid route", []Option{WithLimits("10/s"), WithRoute("", "1/s")}, true},
+		{"Invalid failure policy", []Option{WithLimits("10/s"), WithFailurePolicy("maybe")}, true},
+		{"Invalid error format", []Option{WithLimits("10/s"), WithErrorFormat("xml")}, true},
+		{"Missing env file", []Option{WithEnvConfig()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRedisStrategy(t *testing.T) {
+	for _, algorithm := range []string{"", FixedWindow, TokenBucket, SlidingWindowLog, SlidingWindowCounter, GCRA, LeakyBucket} {
+		if _, err := NewRedisStrategy(nil, algorithm); err != nil {
+			t.Errorf("NewRedisStrategy(%q) error = %v", algorithm, err)
+		}
+	}
+	leakyBucket, _ := NewRedisStrategy(nil, LeakyBucket)
+	if _, ok := middlewareStrategy(leakyBucket).(middleware.ShapingStrategy); ok {
+		t.Error("NewRedisStrategy(LeakyBucket) delays requests, want them rejected")
+	}
+	if _, ok := middlewareStrategy(leakyBucket).(middleware.TTLStrategy); !ok {
+		t.Error("NewRedisStrategy(LeakyBucket) hides the TTL of blacklisted clients")
+	}
+	if _, err := NewRedisStrategy(nil, "random"); err == nil {
+		t.Error("NewRedisStrategy(\"random\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+	"github.com/carlosmeds/rate-limiter/internal/infra/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewMemoryStrategy counts requests with a fixed window in process, for a
+// single instance of a service. Expired counters are cleaned up in the
+// background until the Limiter using the strategy is closed.
+func NewMemoryStrategy() Strategy {
+	store := database.NewMemoryStore(time.Minute)
+	return &storeStrategy{s: store, close: store.Close}
+}
+
+// NewRedisStrategy counts requests in Redis with algorithm, so every
+// instance of a service shares the same limits. Token buckets hold and
+// refill a limit's requests over its window, and leaky buckets reject
+// requests instead of queueing them. The client is left open when the
+// Limiter is closed.
+func NewRedisStrategy(client redis.UniversalClient, algorithm string) (Strategy, error) {
+	repo := &database.RateLimiterRepository{RedisClient: client}
+	switch algorithm {
+	case "", FixedWindow:
+		return &storeStrategy{s: &database.FixedWindowRepository{RateLimiterRepository: repo}}, nil
+	case TokenBucket:
+		return &storeStrategy{s: &database.TokenBucketRepository{RateLimiterRepository: repo}}, nil
+	case SlidingWindowLog:
+		return &storeStrategy{s: &database.SlidingWindowLogRepository{RateLimiterRepository: repo}}, nil
+	case SlidingWindowCounter:
+		return &storeStrategy{s: &database.SlidingWindowCounterRepository{RateLimiterRepository: repo}}, nil
+	case GCRA:
+		return &storeStrategy{s: &database.GCRARepository{RateLimiterRepository: repo}}, nil
+	case LeakyBucket:
+		return &storeStrategy{s: rejectingLeakyBucket{repo: &database.LeakyBucketRepository{RateLimiterRepository: repo}}}, nil
+	}
+	return nil, fmt.Errorf("ratelimit: unknown algorithm %q", algorithm)
+}
+
+// rejectingLeakyBucket hides the ability of a leaky bucket to delay requests
+// from the middleware, so requests over the rate are rejected right away.
+type rejectingLeakyBucket struct {
+	repo *database.LeakyBucketRepository
+}
+
+func (b rejectingLeakyBucket) HasReachedLimit(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+	return b.repo.HasReachedLimit(ctx, key, limits)
+}
+
+func (b rejectingLeakyBucket) Get(ctx context.Context, key string) (string, error) {
+	return b.repo.Get(ctx, key)
+}
+
+func (b rejectingLeakyBucket) Save(ctx context.Context, key, value string, ttl int64) error {
+	return b.repo.Save(ctx, key, value, ttl)
+}
+
+func (b rejectingLeakyBucket) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return b.repo.TTL(ctx, key)
+}
+
+func (b rejectingLeakyBucket) CircuitOpen() bool {
+	return b.repo.CircuitOpen()
+}
+
+// storeStrategy is a Strategy created by this package. The middleware uses
+// its store directly, keeping abilities the Strategy interface cannot
+// express, such as blacklisting within the same script or delaying requests.
+type storeStrategy struct {
+	s     middleware.RateLimiterStrategy
+	close func()
+}
+
+func (s *storeStrategy) HasReachedLimit(ctx context.Context, key string, limits []Limit) (*Result, error) {
+	result, err := s.s.HasReachedLimit(ctx, key, toConfigLimits(limits))
+	if err != nil {
+		return nil, err
+	}
+	return fromLimitResult(result), nil
+}
+
+func (s *storeStrategy) Get(ctx context.Context, key string) (string, error) {
+	return s.s.Get(ctx, key)
+}
+
+func (s *storeStrategy) Save(ctx context.Context, key, value string, ttl int64) error {
+	return s.s.Save(ctx, key, value, ttl)
+}
+
+// customStrategy lets the middleware use a Strategy implemented outside this
+// package.
+type customStrategy struct {
+	Strategy
+}
+
+func (s customStrategy) HasReachedLimit(ctx context.Context, key string, limits []configs.Limit) (*database.LimitResult, error) {
+	result, err := s.Strategy.HasReachedLimit(ctx, key, fromConfigLimits(limits))
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("ratelimit: strategy returned no result")
+	}
+	return toLimitResult(result), nil
+}
+
+func middlewareStrategy(strategy Strategy) middleware.RateLimiterStrategy {
+	if s, ok := strategy.(*storeStrategy); ok {
+		return s.s
+	}
+	return customStrategy{strategy}
+}
+
+// envStrategy creates the strategy configured by the env file, as the server
+// does, along with the strategies of tiers using other algorithms and the
+// function closing them.
+func envStrategy() (middleware.RateLimiterStrategy, func(algorithm string) middleware.RateLimiterStrategy, func()) {
+	strategy := middleware.NewRateLimiterStrategy()
+	closeStrategy := func() {
+		if closer, ok := strategy.(interface{ Close() }); ok {
+			closer.Close()
+		}
+		if redisStrategy, ok := strategy.(interface {
+			Repository() *database.RateLimiterRepository
+		}); ok {
+			redisStrategy.Repository().RedisClient.Close()
+		}
+	}
+	return strategy, middleware.NewAlgorithmStrategies(strategy), closeStrategy
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/carlosmeds/rate-limiter/configs"
+	"github.com/carlosmeds/rate-limiter/internal/infra/database"
+	"github.com/carlosmeds/rate-limiter/internal/infra/middleware"
+)
+
+// Limit allows Requests per Window.
+type Limit struct {
+	Requests int64
+	Window   time.Duration
+}
+
+// Result is the state of the limits a request was counted against. Limit is
+// the limit that tripped or, when none did, the one with the fewest requests
+// Remaining. RetryAfter is how long a rejected client must wait and
+// ResetAfter how long until Limit frees up again.
+type Result struct {
+	Allowed     bool
+	BlackListed bool
+	Limit       Limit
+	Remaining   int64
+	RetryAfter  time.Duration
+	ResetAfter  time.Duration
+}
+
+// Strategy counts requests in a store. HasReachedLimit counts a request of
+// the client identified by key against every limit, while Get and Save keep
+// the blacklist of clients blocked by WithBlockedTime, with ttl in seconds.
+// Get returns an empty value for missing keys.
+type Strategy interface {
+	HasReachedLimit(ctx context.Context, key string, limits []Limit) (*Result, error)
+	Get(ctx context.Context, key string) (string, error)
+	Save(ctx context.Context, key, value string, ttl int64) error
+}
+
+// Decision describes why a request was rejected. Limit is the limit that was
+// exceeded, if any, among the Limits the request was counted against.
+type Decision struct {
+	StatusCode  int
+	Message     string
+	BlackListed bool
+	Limits      []Limit
+	Limit       Limit
+	RetryAfter  time.Duration
+	ResetAfter  time.Duration
+}
+
+// RejectionHandler writes the response to a rejected request. The RateLimit
+// and Retry-After headers are already set on w when it is called.
+type RejectionHandler func(w http.ResponseWriter, r *http.Request, decision Decision)
+
+func rejectionHandler(handler RejectionHandler) middleware.RejectionHandler {
+	if handler == nil {
+		return nil
+	}
+	return func(w http.ResponseWriter, r *http.Request, decision middleware.Decision) {
+		handler(w, r, Decision{
+			StatusCode:  decision.StatusCode,
+			Message:     decision.Message,
+			BlackListed: decision.BlackListed,
+			Limits:      fromConfigLimits(decision.Limits),
+			Limit:       Limit(decision.Limit),
+			RetryAfter:  decision.RetryAfter,
+			ResetAfter:  decision.ResetAfter,
+		})
+	}
+}
+
+func fromConfigLimits(limits []configs.Limit) []Limit {
+	if limits == nil {
+		return nil
+	}
+	converted := make([]Limit, len(limits))
+	for i, limit := range limits {
+		converted[i] = Limit(limit)
+	}
+	return converted
+}
+
+func toConfigLimits(limits []Limit) []configs.Limit {
+	if limits == nil {
+		return nil
+	}
+	converted := make([]configs.Limit, len(limits))
+	for i, limit := range limits {
+		converted[i] = configs.Limit(limit)
+	}
+	return converted
+}
+
+func fromLimitResult(result *database.LimitResult) *Result {
+	return &Result{
+		Allowed:     result.Allowed,
+		BlackListed: result.BlackListed,
+		Limit:       Limit(result.Limit),
+		Remaining:   result.Remaining,
+		RetryAfter:  result.RetryAfter,
+		ResetAfter:  result.ResetAfter,
+	}
+}
+
+func toLimitResult(result *Result) *database.LimitResult {
+	return &database.LimitResult{
+		Allowed:     result.Allowed,
+		BlackListed: result.BlackListed,
+		Limit:       configs.Limit(result.Limit),
+		Remaining:   result.Remaining,
+		RetryAfter:  result.RetryAfter,
+		ResetAfter:  result.ResetAfter,
+	}
+}